@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// tailChunkSize is a number of bytes, which are read at once from the end
+// of history file, while looking for the last message.
+const tailChunkSize = 4096
+
+// filterActiveChannels keeps only history files of channels, which last
+// message was written after specified time. Only the current history file
+// of channel is read, if there is one, since rotated files are older.
+func filterActiveChannels(
+	files []HistoryFile,
+	since time.Time,
+) ([]HistoryFile, error) {
+	var (
+		current = map[string]bool{}
+		last    = map[string]time.Time{}
+	)
+
+	channelOf := func(file HistoryFile) string {
+		return file.Label + "/" + file.Channel()
+	}
+
+	for _, file := range files {
+		if file.Rotation() == 0 {
+			current[channelOf(file)] = true
+		}
+	}
+
+	for _, file := range files {
+		channel := channelOf(file)
+		if current[channel] && file.Rotation() != 0 {
+			continue
+		}
+
+		moment, err := readLastMessageTime(file.Name)
+		if err != nil {
+			err = fileErrors.Collect(err)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if moment.After(last[channel]) {
+			last[channel] = moment
+		}
+	}
+
+	active := []HistoryFile{}
+	for _, file := range files {
+		channel := channelOf(file)
+		if last[channel].After(since) {
+			active = append(active, file)
+		} else {
+			slog.Debug(
+				"skipping inactive channel",
+				"file", file.Name,
+				"last_message", last[channel],
+			)
+		}
+	}
+
+	return active, nil
+}
+
+// readLastMessageTime returns time of the last message of history file,
+// reading file backwards from the end in chunks until header line is found.
+// Zero time is returned for file without messages.
+func readLastMessageTime(path string) (time.Time, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, ser.Errorf(err, "can't open history file %q", path)
+	}
+
+	defer handle.Close()
+
+	stat, err := handle.Stat()
+	if err != nil {
+		return time.Time{}, ser.Errorf(err, "can't stat history file %q", path)
+	}
+
+	var (
+		offset = stat.Size()
+		tail   = []byte{}
+	)
+
+	for offset > 0 {
+		size := min(int64(tailChunkSize), offset)
+		offset -= size
+
+		chunk := make([]byte, size)
+
+		_, err := handle.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return time.Time{}, ser.Errorf(
+				err,
+				"can't read history file %q",
+				path,
+			)
+		}
+
+		tail = append(chunk, tail...)
+
+		// The first line of tail can be incomplete, unless tail starts at
+		// the beginning of file.
+		lines := bytes.Split(tail, []byte("\n"))
+		if offset > 0 {
+			lines = lines[1:]
+		}
+
+		for index := len(lines) - 1; index >= 0; index-- {
+			header, err := parseHeader(string(lines[index]))
+			if err == nil {
+				return header.Time, nil
+			}
+		}
+	}
+
+	return time.Time{}, nil
+}
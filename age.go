@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AgeBucketsReport counts matching messages by their age.
+type AgeBucketsReport struct {
+	// Now is a moment, which age of messages is counted from.
+	Now time.Time
+
+	// Bounds are ascending upper bounds of buckets, except the last bucket,
+	// which has no upper bound. Labels are names of bounds.
+	Bounds []time.Duration
+	Labels []string
+
+	counts []int
+}
+
+// AgeBucket is a number of messages with age in specified range.
+type AgeBucket struct {
+	Bucket   string `json:"bucket"`
+	Messages int    `json:"messages"`
+}
+
+// newAgeBucketsReport creates report with buckets, specified as
+// comma-separated ascending durations, like 1h,24h,168h.
+func newAgeBucketsReport(
+	spec string,
+	now time.Time,
+) (*AgeBucketsReport, error) {
+	report := &AgeBucketsReport{
+		Now: now,
+	}
+
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.TrimSpace(value)
+
+		bound, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
+			)
+		}
+
+		last := len(report.Bounds) - 1
+		if last >= 0 && bound <= report.Bounds[last] {
+			return nil, fmt.Errorf(
+				"age buckets %q should be in ascending order",
+				spec,
+			)
+		}
+
+		report.Bounds = append(report.Bounds, bound)
+		report.Labels = append(report.Labels, value)
+	}
+
+	report.counts = make([]int, len(report.Bounds)+1)
+
+	return report, nil
+}
+
+func (report *AgeBucketsReport) Add(file HistoryFile, message *Message) error {
+	age := report.Now.Sub(message.Header.Time)
+
+	bucket := len(report.Bounds)
+	for index, bound := range report.Bounds {
+		if age < bound {
+			bucket = index
+			break
+		}
+	}
+
+	report.counts[bucket]++
+
+	return nil
+}
+
+func (report *AgeBucketsReport) Print(asJSON bool) error {
+	buckets := []AgeBucket{}
+	for index, count := range report.counts {
+		buckets = append(buckets, AgeBucket{
+			Bucket:   report.label(index),
+			Messages: count,
+		})
+	}
+
+	if asJSON {
+		return printJSON(buckets)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "age\tmessages")
+	for _, bucket := range buckets {
+		fmt.Fprintf(writer, "%s\t%d\n", bucket.Bucket, bucket.Messages)
+	}
+
+	return writer.Flush()
+}
+
+// label returns name of bucket with specified index, like <1h or 1h-24h.
+func (report *AgeBucketsReport) label(index int) string {
+	switch index {
+	case 0:
+		return "<" + report.Labels[0]
+
+	case len(report.Bounds):
+		return ">" + report.Labels[index-1]
+
+	default:
+		return report.Labels[index-1] + "-" + report.Labels[index]
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of identities, which can be replaced by Anonymizer.
+const (
+	AnonymizeNick    = "nick"
+	AnonymizeAddress = "address"
+	AnonymizePhone   = "phone"
+)
+
+var (
+	// addressPattern matches JIDs and emails, optionally with resource.
+	addressPattern = regexp.MustCompile(
+		`[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)+(?:/\S*)?`,
+	)
+
+	// phonePattern matches candidates for phone numbers, which are checked
+	// further by isPhoneNumber.
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d ().-]{6,}\d`)
+
+	// datePattern matches dates, like 2024-01-02 or 02.01.2024, which are
+	// caught by phonePattern along with hours of following time.
+	datePattern = regexp.MustCompile(
+		`\d{4}[./-]\d{1,2}[./-]\d{1,2}|\d{1,2}[./-]\d{1,2}[./-]\d{4}`,
+	)
+)
+
+// Anonymizer replaces identities in printed messages with stable
+// pseudonyms, like User1, which are the same for the same identity during
+// whole run.
+type Anonymizer struct {
+	Kinds map[string]bool
+
+	pseudonyms map[string]string
+	counters   map[string]int
+	mapping    [][2]string
+	nicks      map[string]*regexp.Regexp
+}
+
+// newAnonymizer creates anonymizer for comma-separated list of identity
+// kinds.
+func newAnonymizer(kinds string) (*Anonymizer, error) {
+	anonymizer := &Anonymizer{
+		Kinds:      map[string]bool{},
+		pseudonyms: map[string]string{},
+		counters:   map[string]int{},
+		nicks:      map[string]*regexp.Regexp{},
+	}
+
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.TrimSpace(kind)
+
+		switch kind {
+		case AnonymizeNick, AnonymizeAddress, AnonymizePhone:
+			anonymizer.Kinds[kind] = true
+
+		case "":
+
+		default:
+			return nil, fmt.Errorf(
+				"unknown kind of identities to anonymize %q: should be %s, %s or %s",
+				kind, AnonymizeNick, AnonymizeAddress, AnonymizePhone,
+			)
+		}
+	}
+
+	return anonymizer, nil
+}
+
+// pseudonym returns pseudonym of identity, assigning next one with specified
+// prefix, if identity is new.
+func (anonymizer *Anonymizer) pseudonym(prefix string, identity string) string {
+	key := prefix + "\x00" + strings.ToLower(identity)
+
+	if pseudonym, ok := anonymizer.pseudonyms[key]; ok {
+		return pseudonym
+	}
+
+	anonymizer.counters[prefix]++
+
+	pseudonym := fmt.Sprintf("%s%d", prefix, anonymizer.counters[prefix])
+
+	anonymizer.pseudonyms[key] = pseudonym
+	anonymizer.mapping = append(anonymizer.mapping, [2]string{pseudonym, identity})
+
+	return pseudonym
+}
+
+// nick returns pseudonym of sender nick and remembers nick, so it's
+// replaced in texts of messages too.
+func (anonymizer *Anonymizer) nick(nick string) string {
+	identity := nick
+	if strings.Contains(nick, "@") {
+		identity, _, _ = strings.Cut(nick, "/")
+	}
+
+	key := strings.ToLower(nick)
+	if _, ok := anonymizer.nicks[key]; !ok {
+		anonymizer.nicks[key] = regexp.MustCompile(
+			`(?i)` + regexp.QuoteMeta(nick),
+		)
+	}
+
+	return anonymizer.pseudonym("User", identity)
+}
+
+// channel returns file with channel name replaced by pseudonym, if channel
+// name is JID.
+func (anonymizer *Anonymizer) channel(file HistoryFile) HistoryFile {
+	channel := file.Channel()
+	if !anonymizer.Kinds[AnonymizeAddress] || !strings.Contains(channel, "@") {
+		return file
+	}
+
+	pseudonym := anonymizer.pseudonym("Channel", channel)
+
+	base := filepath.Base(file.Name)
+
+	file.Alias = pseudonym
+	file.Name = filepath.Join(
+		filepath.Dir(file.Name),
+		pseudonym+rotationSuffix.FindString(base),
+	)
+
+	return file
+}
+
+// text replaces addresses, phone numbers and known nicks in text.
+func (anonymizer *Anonymizer) text(text string) string {
+	if anonymizer.Kinds[AnonymizeAddress] {
+		text = addressPattern.ReplaceAllStringFunc(
+			text,
+			func(address string) string {
+				bare, _, _ := strings.Cut(address, "/")
+
+				return anonymizer.pseudonym("User", bare)
+			},
+		)
+	}
+
+	if anonymizer.Kinds[AnonymizePhone] {
+		text = phonePattern.ReplaceAllStringFunc(
+			text,
+			func(candidate string) string {
+				if !isPhoneNumber(candidate) {
+					return candidate
+				}
+
+				return anonymizer.pseudonym("Phone", digitsOf(candidate))
+			},
+		)
+	}
+
+	if anonymizer.Kinds[AnonymizeNick] {
+		nicks := make([]string, 0, len(anonymizer.nicks))
+		for nick := range anonymizer.nicks {
+			nicks = append(nicks, nick)
+		}
+
+		// Longer nicks go first, so nick like "bob.smith" is not broken by
+		// replacing "bob" in it.
+		sort.Slice(nicks, func(i, j int) bool {
+			if len(nicks[i]) != len(nicks[j]) {
+				return len(nicks[i]) > len(nicks[j])
+			}
+
+			return nicks[i] < nicks[j]
+		})
+
+		for _, nick := range nicks {
+			text = replaceWords(
+				text,
+				anonymizer.nicks[nick],
+				anonymizer.nick(nick),
+			)
+		}
+	}
+
+	return text
+}
+
+// Anonymize returns copies of file and message with identities replaced by
+// pseudonyms. Nicks are replaced in texts only after they were seen as
+// sender nicks.
+func (anonymizer *Anonymizer) Anonymize(
+	file HistoryFile,
+	message *Message,
+) (HistoryFile, *Message) {
+	header := *message.Header
+
+	text := header.Message
+	prefix := ""
+
+	if nick := extractNick(message); nick != "" {
+		text = strings.TrimPrefix(text, "<"+nick+">")
+		prefix = "<" + nick + ">"
+
+		if anonymizer.Kinds[AnonymizeNick] {
+			prefix = "<" + anonymizer.nick(nick) + ">"
+		}
+	}
+
+	header.Message = prefix + anonymizer.text(text)
+
+	anonymized := *message
+	anonymized.Header = &header
+	anonymized.Body = nil
+	anonymized.Explanation = anonymizer.text(message.Explanation)
+
+	for _, line := range message.Body {
+		anonymized.Body = append(anonymized.Body, anonymizer.text(line))
+	}
+
+	if anonymizer.Kinds[AnonymizeNick] && len(message.Mentioned) > 0 {
+		anonymized.Mentioned = nil
+		for _, nick := range message.Mentioned {
+			anonymized.Mentioned = append(
+				anonymized.Mentioned,
+				anonymizer.nick(nick),
+			)
+		}
+	}
+
+	if anonymizer.Kinds[AnonymizeAddress] && len(message.CrossPosted) > 0 {
+		anonymized.CrossPosted = nil
+		for _, channel := range message.CrossPosted {
+			if strings.Contains(channel, "@") {
+				channel = anonymizer.pseudonym("Channel", channel)
+			}
+
+			anonymized.CrossPosted = append(anonymized.CrossPosted, channel)
+		}
+	}
+
+	return anonymizer.channel(file), &anonymized
+}
+
+// PrintMap prints pseudonyms along with identities they replace.
+func (anonymizer *Anonymizer) PrintMap(output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+
+	for _, pair := range anonymizer.mapping {
+		fmt.Fprintf(writer, "%s\t%s\n", pair[0], pair[1])
+	}
+
+	return writer.Flush()
+}
+
+// replaceWords replaces matches of pattern in text with replacement, unless
+// match is a part of longer word. Characters around match are only checked,
+// not consumed, so adjacent matches, like "alice alice", are all replaced.
+func replaceWords(
+	text string,
+	pattern *regexp.Regexp,
+	replacement string,
+) string {
+	var (
+		result strings.Builder
+		last   = 0
+	)
+
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:match[0]])
+		after, _ := utf8.DecodeRuneInString(text[match[1]:])
+
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+
+		result.WriteString(text[last:match[0]])
+		result.WriteString(replacement)
+
+		last = match[1]
+	}
+
+	result.WriteString(text[last:])
+
+	return result.String()
+}
+
+func isWordRune(char rune) bool {
+	return char == '_' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// isPhoneNumber reports whether candidate looks like phone number rather
+// than date, time or IP address: it has from 9 to 15 digits.
+func isPhoneNumber(candidate string) bool {
+	if net.ParseIP(candidate) != nil || datePattern.MatchString(candidate) {
+		return false
+	}
+
+	digits := len(digitsOf(candidate))
+
+	return digits >= 9 && digits <= 15
+}
+
+func digitsOf(text string) string {
+	return strings.Map(
+		func(char rune) rune {
+			if char >= '0' && char <= '9' {
+				return char
+			}
+
+			return -1
+		},
+		text,
+	)
+}
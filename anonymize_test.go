@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymizerText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ping alice alice", "ping User1 User1"},
+		{"alice,alice:Alice", "User1,User1:User1"},
+		{"malice and alice_bot", "malice and alice_bot"},
+		{"deploy at 2024-01-02 15:04", "deploy at 2024-01-02 15:04"},
+		{"on 02.01.2024 10:30", "on 02.01.2024 10:30"},
+		{"call +1 (555) 123-4567", "call Phone1"},
+		{"mail bob@example.com", "mail User2"},
+		{"host 192.168.100.200", "host 192.168.100.200"},
+	}
+
+	for _, test := range tests {
+		anonymizer, err := newAnonymizer("nick,address,phone")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		anonymizer.nick("alice")
+		anonymizer.pseudonym("User", "bob@example.com")
+
+		got := anonymizer.text(test.text)
+		if got != test.want {
+			t.Errorf("text(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestAnonymizeExplanation(t *testing.T) {
+	anonymizer, err := newAnonymizer("nick,address,phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := &Message{
+		Header: &Header{
+			Time:    time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC),
+			Message: "<alice> ask bob@example.com",
+		},
+		Explanation: `"bob@example.com" matched "bob@example.com" at 4-19`,
+	}
+
+	_, anonymized := anonymizer.Anonymize(HistoryFile{Name: "work"}, message)
+
+	if strings.Contains(anonymized.Explanation, "bob@example.com") {
+		t.Errorf(
+			"explanation is not anonymized: %q",
+			anonymized.Explanation,
+		)
+	}
+
+	if strings.Contains(anonymized.Header.Message, "alice") {
+		t.Errorf("nick is not anonymized: %q", anonymized.Header.Message)
+	}
+}
+
+func TestAnonymizerOverlappingNicks(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		anonymizer, err := newAnonymizer("nick")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		anonymizer.nick("bob")
+		anonymizer.nick("bob.smith")
+
+		got := anonymizer.text("bob and bob.smith")
+		if got != "User1 and User2" {
+			t.Fatalf("got %q, want %q", got, "User1 and User2")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/reconquest/ser-go"
+)
+
+// walkArchive calls handler for history files, stored in gzipped tarball,
+// without extracting them. Entries are selected by matching their base names
+// against channel prefix glob, like files in history directory.
+func walkArchive(
+	archive string,
+	selector ChannelSelector,
+	maxSize int64,
+	handler func(file HistoryFile, reader io.Reader) error,
+) error {
+	handle, err := os.Open(archive)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't open archive %q",
+			archive,
+		)
+	}
+
+	defer handle.Close()
+
+	decompressor, err := gzip.NewReader(handle)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't decompress archive %q",
+			archive,
+		)
+	}
+
+	defer decompressor.Close()
+
+	var (
+		reader = tar.NewReader(decompressor)
+		found  = false
+	)
+
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return ser.Errorf(
+				err,
+				"can't read archive %q",
+				archive,
+			)
+		}
+
+		if entry.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		matched, err := selector.Match(entry.Name)
+		if err != nil {
+			return ser.Errorf(
+				err,
+				"can't match archive entry %q against %q",
+				entry.Name,
+				selector,
+			)
+		}
+
+		if !matched {
+			continue
+		}
+
+		found = true
+
+		if maxSize > 0 && entry.Size > maxSize {
+			slog.Warn(
+				"skipping archive entry larger than --max-file-size",
+				"entry", entry.Name,
+				"size", entry.Size,
+			)
+
+			stats.SkippedLarge++
+
+			continue
+		}
+
+		stats.Files++
+
+		err = handler(
+			HistoryFile{
+				Name:    entry.Name,
+				Alias:   selector.Alias(entry.Name),
+				Archive: archive,
+			},
+			reader,
+		)
+		if err != nil {
+			return ser.Errorf(
+				err,
+				"can't process archive %q",
+				archive,
+			)
+		}
+	}
+
+	if !found {
+		return ser.Errorf(
+			nil,
+			"no history files found in archive %q (%q)",
+			archive,
+			selector,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiveMalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.tar.gz")
+
+	writeArchive(t, path, "history/work",
+		"MR 20240102T10:00:00Z 000 <alice> hello\n"+
+			"not a header\n",
+	)
+
+	_, err := runSearch(t,
+		"--archive", path, "--since", "100000h", "--fail-fast", "work",
+	)
+	if err == nil {
+		t.Fatal("malformed line should fail search with --fail-fast")
+	}
+
+	location := path + ":history/work"
+	if !strings.Contains(err.Error(), location) {
+		t.Errorf("error %q doesn't name %q", err, location)
+	}
+}
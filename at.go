@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/reconquest/ser-go"
+)
+
+// GetMessageAt parses single message, which header line starts at specified
+// byte offset of history file.
+func GetMessageAt(path string, offset int64) (*Message, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open history file %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	if offset > 0 {
+		previous := make([]byte, 1)
+
+		_, err := handle.ReadAt(previous, offset-1)
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't read history file %q at offset %d",
+				path,
+				offset,
+			)
+		}
+
+		if previous[0] != '\n' {
+			return nil, fmt.Errorf(
+				"offset %d doesn't point to line start in %q",
+				offset,
+				path,
+			)
+		}
+	}
+
+	_, err = handle.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't seek history file %q to offset %d",
+			path,
+			offset,
+		)
+	}
+
+	var found *Message
+
+	err = readMessages(path, handle, func(message *Message) error {
+		message.Offset += offset
+		found = message
+
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, ser.Errorf(
+			err,
+			"offset %d doesn't point to message header in %q",
+			offset,
+			path,
+		)
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf(
+			"no message at offset %d in %q",
+			offset,
+			path,
+		)
+	}
+
+	return found, nil
+}
+
+// printMessageAt prints message at location, specified by --at flag.
+func printMessageAt(args map[string]interface{}) error {
+	path, offset, err := parseLocation(args["--at"].(string))
+	if err != nil {
+		return err
+	}
+
+	message, err := GetMessageAt(path, offset)
+	if err != nil {
+		return err
+	}
+
+	searcher, err := newSearcher(args, nil)
+	if err != nil {
+		return err
+	}
+
+	return searcher.output(HistoryFile{Name: path}, message)
+}
+
+// parseLocation parses location in form of <file>:<offset>, optionally
+// followed by :<size>, as printed by --show-offsets.
+func parseLocation(location string) (string, int64, error) {
+	parts := strings.Split(location, ":")
+	if len(parts) < 2 {
+		return "", 0, fmt.Errorf(
+			"location %q should be in form of <file>:<offset>",
+			location,
+		)
+	}
+
+	if len(parts) > 2 {
+		_, sizeErr := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+		_, offsetErr := strconv.ParseInt(parts[len(parts)-2], 10, 64)
+
+		if sizeErr == nil && offsetErr == nil {
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	offset, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf(
+			"can't parse offset in location %q: %s",
+			location, err,
+		)
+	}
+
+	return strings.Join(parts[:len(parts)-1], ":"), offset, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"text/tabwriter"
+	"time"
+)
+
+// Attachment is a file, shared in chat.
+type Attachment struct {
+	Channel  string    `json:"channel"`
+	Time     time.Time `json:"time"`
+	URL      string    `json:"url"`
+	Filename string    `json:"filename"`
+}
+
+// extractAttachment returns URL of file, shared by message. History doesn't
+// mark file shares, so message is considered to be file share, if its whole
+// content is single URL: that's how XMPP clients send out-of-band data
+// (XEP-0066) and HTTP uploads (XEP-0363), including encrypted ones with
+// aesgcm:// scheme.
+func extractAttachment(message *Message) (string, bool) {
+	content := message.Content()
+
+	urls := extractURLs(content)
+	if len(urls) != 1 || urls[0] != content {
+		return "", false
+	}
+
+	return urls[0], true
+}
+
+// attachmentFilename returns name of shared file: last element of URL path.
+func attachmentFilename(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Path == "" || parsed.Path == "/" {
+		return ""
+	}
+
+	name, err := url.PathUnescape(path.Base(parsed.Path))
+	if err != nil {
+		return path.Base(parsed.Path)
+	}
+
+	return name
+}
+
+// AttachmentsReport lists files, shared in matching messages.
+type AttachmentsReport struct {
+	attachments []Attachment
+}
+
+func (report *AttachmentsReport) Add(file HistoryFile, message *Message) error {
+	link, ok := extractAttachment(message)
+	if !ok {
+		return nil
+	}
+
+	report.attachments = append(report.attachments, Attachment{
+		Channel:  file.Channel(),
+		Time:     message.Header.Time,
+		URL:      link,
+		Filename: attachmentFilename(link),
+	})
+
+	return nil
+}
+
+func (report *AttachmentsReport) Print(asJSON bool) error {
+	if asJSON {
+		attachments := report.attachments
+		if attachments == nil {
+			attachments = []Attachment{}
+		}
+
+		return printJSON(attachments)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	for _, attachment := range report.attachments {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\n",
+			attachment.Time.Format(time.ANSIC),
+			attachment.Channel,
+			attachment.Filename,
+			attachment.URL,
+		)
+	}
+
+	return writer.Flush()
+}
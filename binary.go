@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// escapeControls replaces control characters, except tab, and bytes, which
+// are not valid UTF-8, with \xNN escapes of their bytes, like \x00 or \x1b,
+// so text can be safely matched and printed. Backslashes are left as is.
+func escapeControls(text string) string {
+	if !hasControls(text) {
+		return text
+	}
+
+	escaped := strings.Builder{}
+
+	for len(text) > 0 {
+		char, size := utf8.DecodeRuneInString(text)
+
+		if (char == utf8.RuneError && size == 1) || isControl(char) {
+			for _, value := range []byte(text[:size]) {
+				fmt.Fprintf(&escaped, `\x%02x`, value)
+			}
+		} else {
+			escaped.WriteString(text[:size])
+		}
+
+		text = text[size:]
+	}
+
+	return escaped.String()
+}
+
+func hasControls(text string) bool {
+	if !utf8.ValidString(text) {
+		return true
+	}
+
+	for _, char := range text {
+		if isControl(char) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isControl reports whether char is C0 or C1 control character, except tab.
+func isControl(char rune) bool {
+	return char != '\t' &&
+		(char < 0x20 || (char >= 0x7f && char < 0xa0))
+}
+
+// escapeMessageControls returns copy of message with escaped control
+// characters in header message and body lines.
+func escapeMessageControls(message *Message) *Message {
+	header := *message.Header
+	header.Message = escapeControls(header.Message)
+
+	escaped := *message
+	escaped.Header = &header
+	escaped.Body = nil
+
+	for _, line := range message.Body {
+		escaped.Body = append(escaped.Body, escapeControls(line))
+	}
+
+	return &escaped
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// browseContext is a number of messages, printed before and after match in
+// context view of browser.
+const browseContext = 10
+
+// browseMatch is a matching message along with history file it belongs to.
+type browseMatch struct {
+	cached  *cachedFile
+	message *Message
+}
+
+// browser is a full-screen front-end over cached history: it lists matches
+// of filter, which is typed by user and re-run on every key press, in all
+// or in single selected channel, and shows context of selected match.
+type browser struct {
+	args  map[string]interface{}
+	cache []cachedFile
+
+	// channels are names of cached channels; channel is an index of selected
+	// one, where -1 means all channels.
+	channels []string
+	channel  int
+
+	filter   string
+	matches  []browseMatch
+	selected int
+	top      int
+	err      error
+
+	// context is a list of messages around selected match, which is shown
+	// instead of matches, if not nil.
+	context     []*Message
+	contextAt   int
+	contextFile HistoryFile
+}
+
+// browse parses history of specified channels once and runs full-screen
+// browser over it until user quits.
+func browse(args map[string]interface{}) error {
+	if args["--output"] != nil {
+		return fmt.Errorf("--browse can't be used with --output")
+	}
+
+	input := int(os.Stdin.Fd())
+	if !term.IsTerminal(input) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("--browse requires terminal")
+	}
+
+	cache, err := readCache(args)
+	if err != nil {
+		return err
+	}
+
+	browser := &browser{
+		args:    args,
+		cache:   cache,
+		channel: -1,
+	}
+
+	seen := map[string]bool{}
+	for _, cached := range cache {
+		channel := browser.channelOf(cached.file)
+		if !seen[channel] {
+			seen[channel] = true
+			browser.channels = append(browser.channels, channel)
+		}
+	}
+
+	state, err := term.MakeRaw(input)
+	if err != nil {
+		return fmt.Errorf("can't switch terminal to raw mode: %s", err)
+	}
+
+	// Alternate screen keeps scrollback of terminal intact.
+	fmt.Fprint(stdout, "\x1b[?1049h")
+
+	defer func() {
+		fmt.Fprint(stdout, "\x1b[?1049l")
+		term.Restore(input, state)
+	}()
+
+	browser.search()
+
+	buffer := make([]byte, 64)
+	for {
+		browser.render()
+
+		size, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return err
+		}
+
+		if !browser.handle(string(buffer[:size])) {
+			return nil
+		}
+	}
+}
+
+func (browser *browser) channelOf(file HistoryFile) string {
+	if file.Label != "" {
+		return file.Label + "/" + file.Channel()
+	}
+
+	return file.Channel()
+}
+
+// search re-runs search of current filter in selected channels. Matches are
+// kept as is if filter is invalid, so typing regexp doesn't clear screen.
+func (browser *browser) search() {
+	searcher, err := newSearcher(browser.args, strings.Fields(browser.filter))
+	if err != nil {
+		browser.err = err
+
+		return
+	}
+
+	matches := []browseMatch{}
+
+	for index := range browser.cache {
+		cached := &browser.cache[index]
+
+		if browser.channel >= 0 &&
+			browser.channelOf(cached.file) != browser.channels[browser.channel] {
+			continue
+		}
+
+		searcher.Collect = func(file HistoryFile, message *Message) error {
+			matches = append(matches, browseMatch{
+				cached:  cached,
+				message: message,
+			})
+
+			return nil
+		}
+
+		err := searcher.SearchMessages(cached.file, cached.messages)
+		if err != nil {
+			browser.err = err
+
+			return
+		}
+
+		err = searcher.Flush()
+		if err != nil {
+			browser.err = err
+
+			return
+		}
+	}
+
+	browser.err = nil
+	browser.matches = matches
+	browser.selected = 0
+	browser.top = 0
+}
+
+// handle processes key press and returns false, if browser should quit.
+func (browser *browser) handle(key string) bool {
+	_, height, _ := term.GetSize(int(os.Stdout.Fd()))
+	page := height - 2
+
+	switch key {
+	case "\x03", "\x04":
+		return false
+
+	case "\x1b":
+		if browser.context == nil {
+			return false
+		}
+
+		browser.context = nil
+
+	case "\r":
+		if browser.context != nil {
+			browser.context = nil
+		} else {
+			browser.openContext()
+		}
+
+	case "\x1b[A":
+		browser.move(-1)
+
+	case "\x1b[B":
+		browser.move(1)
+
+	case "\x1b[5~":
+		browser.move(-page)
+
+	case "\x1b[6~":
+		browser.move(page)
+
+	case "\t", "\x1b[Z":
+		if browser.context != nil {
+			break
+		}
+
+		count := len(browser.channels) + 1
+		step := 1
+		if key == "\x1b[Z" {
+			step = count - 1
+		}
+
+		browser.channel = (browser.channel+1+step)%count - 1
+		browser.search()
+
+	case "\x7f", "\x08":
+		if browser.context != nil || browser.filter == "" {
+			break
+		}
+
+		_, size := utf8.DecodeLastRuneInString(browser.filter)
+		browser.filter = browser.filter[:len(browser.filter)-size]
+		browser.search()
+
+	case "\x15":
+		if browser.context != nil {
+			break
+		}
+
+		browser.filter = ""
+		browser.search()
+
+	default:
+		if browser.context != nil || strings.HasPrefix(key, "\x1b") {
+			break
+		}
+
+		for _, char := range key {
+			if unicode.IsPrint(char) {
+				browser.filter += string(char)
+			}
+		}
+
+		browser.search()
+	}
+
+	return true
+}
+
+// move moves selection in matches or scrolls context by specified number of
+// lines.
+func (browser *browser) move(delta int) {
+	if browser.context != nil {
+		browser.top = clamp(browser.top+delta, 0, len(browser.context)-1)
+
+		return
+	}
+
+	browser.selected = clamp(browser.selected+delta, 0, len(browser.matches)-1)
+}
+
+// openContext shows messages around selected match in its history file.
+func (browser *browser) openContext() {
+	if len(browser.matches) == 0 {
+		return
+	}
+
+	match := browser.matches[browser.selected]
+	messages := match.cached.messages
+
+	at := 0
+	for index, message := range messages {
+		if message.Offset == match.message.Offset {
+			at = index
+			break
+		}
+	}
+
+	start := clamp(at-browseContext, 0, len(messages))
+	end := clamp(at+browseContext+1, 0, len(messages))
+
+	browser.context = messages[start:end]
+	browser.contextAt = at - start
+	browser.contextFile = match.cached.file
+	browser.top = 0
+}
+
+// render draws whole screen: status line, matches or context and filter
+// prompt.
+func (browser *browser) render() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height < 3 {
+		width, height = 80, 24
+	}
+
+	rows := height - 2
+	lines := []string{}
+
+	if browser.context != nil {
+		lines = append(lines, fmt.Sprintf(
+			"\x1b[1mcontext in %s\x1b[0m (Enter or Esc to go back)",
+			browser.channelOf(browser.contextFile),
+		))
+
+		for index, message := range browser.context[browser.top:] {
+			line := browser.formatLine("", message, width)
+			if browser.top+index == browser.contextAt {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+
+			lines = append(lines, line)
+		}
+	} else {
+		channel := "all channels"
+		if browser.channel >= 0 {
+			channel = browser.channels[browser.channel]
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"\x1b[1m%s\x1b[0m: %d matches "+
+				"(Tab to switch channel, Enter for context, Esc to quit)",
+			channel, len(browser.matches),
+		))
+
+		if browser.selected < browser.top {
+			browser.top = browser.selected
+		}
+
+		if browser.selected >= browser.top+rows {
+			browser.top = browser.selected - rows + 1
+		}
+
+		for index := browser.top; index < len(browser.matches); index++ {
+			match := browser.matches[index]
+
+			line := browser.formatLine(
+				browser.channelOf(match.cached.file),
+				match.message,
+				width,
+			)
+			if index == browser.selected {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > rows+1 {
+		lines = lines[:rows+1]
+	}
+
+	for len(lines) < rows+1 {
+		lines = append(lines, "")
+	}
+
+	prompt := "filter> " + browser.filter
+	if browser.err != nil {
+		prompt = "\x1b[31m" + fitWidth(browser.err.Error(), width) + "\x1b[0m"
+	}
+
+	fmt.Fprint(
+		stdout,
+		"\x1b[H\x1b[2J"+strings.Join(lines, "\r\n")+"\r\n"+prompt,
+	)
+}
+
+// formatLine renders first line of message with its time and, optionally,
+// channel, truncated to specified width.
+func (browser *browser) formatLine(
+	channel string,
+	message *Message,
+	width int,
+) string {
+	text, _, _ := strings.Cut(stripFormatting(message.Text()), "\n")
+	if len(message.Body) > 0 {
+		text += " …"
+	}
+
+	line := message.Header.Time.Format("2006-01-02 15:04") + " "
+	if channel != "" {
+		line += channel + " "
+	}
+
+	return fitWidth(line+text, width)
+}
+
+// fitWidth cuts text to specified number of characters.
+func fitWidth(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+
+	return string([]rune(text)[:width])
+}
+
+func clamp(value, min, max int) int {
+	if value > max {
+		value = max
+	}
+
+	if value < min {
+		value = min
+	}
+
+	return value
+}
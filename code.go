@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	// CodeFence is a reason of code detection for messages with ``` markers.
+	CodeFence = "fence"
+
+	// CodeIndent is a reason of code detection for multi-line messages with
+	// indented lines.
+	CodeIndent = "indent"
+)
+
+// CodeDetector detects messages, which look like pasted code: multi-line
+// messages with indented lines or messages with fenced ``` blocks.
+type CodeDetector struct {
+	// MinLines is a minimum number of lines in message, including header
+	// line, to detect it as indented paste.
+	MinLines int
+
+	// Fences enables detection by ``` markers.
+	Fences bool
+}
+
+// Detect returns reason, why message is detected as code, or empty string
+// if it is not.
+func (detector *CodeDetector) Detect(message *Message) string {
+	if detector.Fences && strings.Contains(message.Text(), "```") {
+		return CodeFence
+	}
+
+	if len(message.Body)+1 < detector.MinLines {
+		return ""
+	}
+
+	for _, line := range message.Body {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "  ") {
+			return CodeIndent
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// correctionWindow is maximum time between message and its correction.
+	correctionWindow = 2 * time.Minute
+
+	// correctionSimilarity is minimum similarity between message and its
+	// correction, from zero for completely different texts to one for
+	// identical ones.
+	correctionSimilarity = 0.75
+
+	// correctionMaxLength limits length of texts, which are compared, to
+	// keep detection cheap.
+	correctionMaxLength = 500
+)
+
+// correctionDetector detects messages, which correct previous messages of
+// the same sender. History format doesn't mark corrections, so detection is
+// heuristic: correction is a message, sent by same sender within short
+// window after previous one, with nearly identical, but not the same, text.
+type correctionDetector struct {
+	previous map[string]*Message
+}
+
+func newCorrectionDetector() *correctionDetector {
+	return &correctionDetector{
+		previous: map[string]*Message{},
+	}
+}
+
+// Detect reports whether message corrects previous message of its sender.
+// Messages should be passed in chronological order.
+func (detector *correctionDetector) Detect(message *Message) bool {
+	sender := string(message.Header.Direction) + extractNick(message)
+
+	previous := detector.previous[sender]
+	detector.previous[sender] = message
+
+	if previous == nil {
+		return false
+	}
+
+	if message.Header.Time.Sub(previous.Header.Time) > correctionWindow {
+		return false
+	}
+
+	var (
+		before = []rune(previous.Text())
+		after  = []rune(message.Text())
+	)
+
+	if string(before) == string(after) {
+		return false
+	}
+
+	if len(before) > correctionMaxLength || len(after) > correctionMaxLength {
+		return false
+	}
+
+	return similarity(before, after) >= correctionSimilarity
+}
+
+// similarity returns one minus Levenshtein distance between texts, divided
+// by length of longer text.
+func similarity(a, b []rune) float64 {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			next := min(row[j]+1, row[j-1]+1, diagonal+cost)
+
+			diagonal = row[j]
+			row[j] = next
+		}
+	}
+
+	return row[len(b)]
+}
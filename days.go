@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+)
+
+// ActiveDaysReport counts distinct calendar days in local time, on which
+// matching messages were written, in every channel and in total.
+type ActiveDaysReport struct {
+	channels map[string]map[string]bool
+	total    map[string]bool
+}
+
+// ChannelActiveDays is a number of days with matching messages in single
+// channel.
+type ChannelActiveDays struct {
+	Channel    string `json:"channel"`
+	ActiveDays int    `json:"active_days"`
+}
+
+func (report *ActiveDaysReport) Add(file HistoryFile, message *Message) error {
+	if report.channels == nil {
+		report.channels = map[string]map[string]bool{}
+		report.total = map[string]bool{}
+	}
+
+	channel := file.Channel()
+	if file.Label != "" {
+		channel = file.Label + "/" + channel
+	}
+
+	if report.channels[channel] == nil {
+		report.channels[channel] = map[string]bool{}
+	}
+
+	day := message.Header.Time.Local().Format("2006-01-02")
+
+	report.channels[channel][day] = true
+	report.total[day] = true
+
+	return nil
+}
+
+func (report *ActiveDaysReport) Print(asJSON bool) error {
+	channels := []ChannelActiveDays{}
+	for channel, days := range report.channels {
+		channels = append(channels, ChannelActiveDays{
+			Channel:    channel,
+			ActiveDays: len(days),
+		})
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].ActiveDays == channels[j].ActiveDays {
+			return channels[i].Channel < channels[j].Channel
+		}
+
+		return channels[i].ActiveDays > channels[j].ActiveDays
+	})
+
+	if asJSON {
+		return printJSON(struct {
+			Channels []ChannelActiveDays `json:"channels"`
+			Total    int                 `json:"total"`
+		}{
+			Channels: channels,
+			Total:    len(report.total),
+		})
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "channel\tactive days")
+
+	for _, channel := range channels {
+		fmt.Fprintf(writer, "%s\t%d\n", channel.Channel, channel.ActiveDays)
+	}
+
+	fmt.Fprintf(writer, "total\t%d\n", len(report.total))
+
+	return writer.Flush()
+}
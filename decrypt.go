@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/reconquest/ser-go"
+)
+
+// DecryptMap maps IDs of encrypted messages, like OMEMO or OTR ones, which
+// are stored in history as placeholders, to their plaintext, decrypted out
+// of band.
+type DecryptMap map[string]string
+
+// decryptUnescaper unescapes plaintext in decrypt map.
+var decryptUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+// readDecryptMap reads decrypt map from file with lines in form of
+// <id>=<plaintext>, skipping blank lines and comments, starting with #. IDs
+// are ones, printed by --with-id; newlines in plaintext are written as \n
+// and backslashes as \\.
+func readDecryptMap(path string) (DecryptMap, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open decrypt map %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	plaintexts := DecryptMap{}
+
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, plaintext, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, ser.Errorf(
+				nil,
+				"can't parse decrypt map %q line %q: should be <id>=<text>",
+				path,
+				line,
+			)
+		}
+
+		plaintexts[strings.ToLower(strings.TrimSpace(id))] =
+			decryptUnescaper.Replace(plaintext)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't read decrypt map %q",
+			path,
+		)
+	}
+
+	return plaintexts, nil
+}
+
+// Decrypt returns copy of message with text replaced by plaintext, if it is
+// mapped, keeping sender nick, or message as is otherwise.
+func (plaintexts DecryptMap) Decrypt(
+	file HistoryFile,
+	message *Message,
+) *Message {
+	id := messageID(file, message)
+
+	plaintext, ok := plaintexts[id]
+	if !ok {
+		return message
+	}
+
+	lines := strings.Split(plaintext, "\n")
+
+	header := *message.Header
+	header.Message = lines[0]
+	header.Length = len(lines) - 1
+
+	if nick := extractNick(message); nick != "" {
+		header.Message = "<" + nick + "> " + lines[0]
+	}
+
+	decrypted := *message
+	decrypted.Header = &header
+	decrypted.Body = lines[1:]
+	decrypted.DecryptedID = id
+
+	return &decrypted
+}
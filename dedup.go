@@ -0,0 +1,92 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// crossPostWindow is maximum difference between times of the same message,
+// posted to different channels.
+const crossPostWindow = 10 * time.Second
+
+// dedupCrossChannel removes messages with the same text, which were posted
+// in different channels within crossPostWindow, from chronologically sorted
+// list, keeping the first one and recording other channels in it.
+func dedupCrossChannel(messages []scoredMessage) []scoredMessage {
+	var (
+		kept    = []scoredMessage{}
+		byText  = map[uint64][]int{}
+		updated = map[int]*Message{}
+	)
+
+	for _, candidate := range messages {
+		var (
+			key     = hashCrossPostText(candidate.message)
+			channel = candidate.file.Channel()
+			dup     = -1
+		)
+
+		for _, index := range byText[key] {
+			original := kept[index]
+
+			if candidate.message.Header.Time.Sub(
+				original.message.Header.Time,
+			) > crossPostWindow {
+				continue
+			}
+
+			if original.file.Channel() == channel {
+				continue
+			}
+
+			if messageBody(original.message) !=
+				messageBody(candidate.message) {
+				continue
+			}
+
+			dup = index
+			break
+		}
+
+		if dup < 0 {
+			byText[key] = append(byText[key], len(kept))
+			kept = append(kept, candidate)
+
+			continue
+		}
+
+		// Message is copied before modification, because it can be shared
+		// with context or other buffers.
+		if updated[dup] == nil {
+			copied := *kept[dup].message
+			copied.CrossPosted = append([]string{}, copied.CrossPosted...)
+
+			updated[dup] = &copied
+			kept[dup].message = &copied
+		}
+
+		updated[dup].CrossPosted = append(updated[dup].CrossPosted, channel)
+	}
+
+	return kept
+}
+
+// messageBody returns message text without sender nick, because bridges can
+// render nicks differently.
+func messageBody(message *Message) string {
+	text := message.Text()
+
+	if nick := extractNick(message); nick != "" {
+		text = strings.TrimPrefix(text, "<"+nick+"> ")
+	}
+
+	return text
+}
+
+func hashCrossPostText(message *Message) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(messageBody(message)))
+
+	return hash.Sum64()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// diffTermsCount is a number of trending terms to print in diff report.
+	diffTermsCount = 10
+
+	// diffTermMinLength is a minimal length of word in runes, which is
+	// counted as term; shorter words are mostly not meaningful.
+	diffTermMinLength = 3
+)
+
+// DiffReport compares messages, written since Since, with older messages,
+// which form baseline window.
+type DiffReport struct {
+	Since time.Time
+
+	baseline int
+	added    []scoredMessage
+
+	baselineTerms map[string]int
+	addedTerms    map[string]int
+}
+
+// Trend is a term, which is used more often in new window than in baseline
+// one.
+type Trend struct {
+	Term     string `json:"term"`
+	Baseline int    `json:"baseline"`
+	Added    int    `json:"added"`
+}
+
+func (report *DiffReport) Add(file HistoryFile, message *Message) error {
+	if report.baselineTerms == nil {
+		report.baselineTerms = map[string]int{}
+		report.addedTerms = map[string]int{}
+	}
+
+	terms := report.addedTerms
+
+	if message.Header.Time.Before(report.Since) {
+		report.baseline++
+
+		terms = report.baselineTerms
+	} else {
+		report.added = append(
+			report.added,
+			scoredMessage{file: file, message: message},
+		)
+	}
+
+	for _, word := range splitWords(message.Content()) {
+		if utf8.RuneCountInString(word) < diffTermMinLength {
+			continue
+		}
+
+		terms[strings.ToLower(word)]++
+	}
+
+	return nil
+}
+
+func (report *DiffReport) Print(asJSON bool) error {
+	trends := report.trends()
+
+	if asJSON {
+		messages := []JSONMessage{}
+		for _, added := range report.added {
+			messages = append(
+				messages,
+				newJSONMessage(added.file, added.message),
+			)
+		}
+
+		return printJSON(map[string]interface{}{
+			"baseline": report.baseline,
+			"added":    len(report.added),
+			"messages": messages,
+			"trends":   trends,
+		})
+	}
+
+	fmt.Fprintf(
+		stdout,
+		"baseline: %d messages, new: %d messages (%+d)\n",
+		report.baseline,
+		len(report.added),
+		len(report.added)-report.baseline,
+	)
+
+	for _, added := range report.added {
+		fmt.Fprintln(
+			stdout,
+			color.GreenString("+")+" "+formatMessage(added.message),
+		)
+	}
+
+	if len(trends) == 0 {
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "trending terms:")
+	fmt.Fprintln(writer, "term\tbaseline\tnew")
+	for _, trend := range trends {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%d\n",
+			trend.Term,
+			trend.Baseline,
+			trend.Added,
+		)
+	}
+
+	return writer.Flush()
+}
+
+// trends returns terms, which share among all terms increased most in new
+// window compared to baseline one. Terms, used only once, are skipped.
+func (report *DiffReport) trends() []Trend {
+	var (
+		baselineTotal = 0
+		addedTotal    = 0
+	)
+
+	for _, count := range report.baselineTerms {
+		baselineTotal += count
+	}
+
+	for _, count := range report.addedTerms {
+		addedTotal += count
+	}
+
+	share := func(count int, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+
+		return float64(count) / float64(total)
+	}
+
+	var (
+		trends = []Trend{}
+		growth = map[string]float64{}
+	)
+
+	for term, count := range report.addedTerms {
+		if count < 2 {
+			continue
+		}
+
+		baseline := report.baselineTerms[term]
+
+		growth[term] = share(count, addedTotal) -
+			share(baseline, baselineTotal)
+		if growth[term] <= 0 {
+			continue
+		}
+
+		trends = append(trends, Trend{
+			Term:     term,
+			Baseline: baseline,
+			Added:    count,
+		})
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if growth[trends[i].Term] != growth[trends[j].Term] {
+			return growth[trends[i].Term] > growth[trends[j].Term]
+		}
+
+		return trends[i].Term < trends[j].Term
+	})
+
+	if len(trends) > diffTermsCount {
+		trends = trends[:diffTermsCount]
+	}
+
+	return trends
+}
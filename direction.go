@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+)
+
+// DirectionReport counts matching messages by direction, either in total or
+// per channel.
+type DirectionReport struct {
+	PerChannel bool
+
+	channels []string
+	counts   map[string]*DirectionCounts
+}
+
+// DirectionCounts is a number of sent, received and info messages.
+type DirectionCounts struct {
+	Channel  string `json:"channel,omitempty"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+	Info     int    `json:"info"`
+}
+
+func (report *DirectionReport) Add(file HistoryFile, message *Message) error {
+	if report.counts == nil {
+		report.counts = map[string]*DirectionCounts{}
+	}
+
+	channel := ""
+	if report.PerChannel {
+		channel = file.Channel()
+		if file.Label != "" {
+			channel = file.Label + "/" + channel
+		}
+	}
+
+	counts, ok := report.counts[channel]
+	if !ok {
+		counts = &DirectionCounts{Channel: channel}
+
+		report.counts[channel] = counts
+		report.channels = append(report.channels, channel)
+	}
+
+	switch message.Header.Direction {
+	case DirectionSend:
+		counts.Sent++
+
+	case DirectionRecv:
+		counts.Received++
+
+	case DirectionInfo:
+		counts.Info++
+	}
+
+	return nil
+}
+
+func (report *DirectionReport) Print(asJSON bool) error {
+	// Total counts are printed even if nothing matched.
+	if !report.PerChannel && len(report.channels) == 0 {
+		report.counts = map[string]*DirectionCounts{"": {}}
+		report.channels = []string{""}
+	}
+
+	if asJSON {
+		if !report.PerChannel {
+			return printJSON(report.counts[""])
+		}
+
+		counts := []*DirectionCounts{}
+		for _, channel := range report.channels {
+			counts = append(counts, report.counts[channel])
+		}
+
+		return printJSON(counts)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	if report.PerChannel {
+		fmt.Fprint(writer, "channel\t")
+	}
+
+	fmt.Fprintln(writer, "sent\treceived\tinfo")
+
+	for _, channel := range report.channels {
+		counts := report.counts[channel]
+
+		if report.PerChannel {
+			fmt.Fprintf(writer, "%s\t", channel)
+		}
+
+		fmt.Fprintf(
+			writer,
+			"%d\t%d\t%d\n",
+			counts.Sent,
+			counts.Received,
+			counts.Info,
+		)
+	}
+
+	return writer.Flush()
+}
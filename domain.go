@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// URLDomainMatcher finds URLs, which host is specified domain or its
+// subdomain. Hosts, as well as domain, are compared in Unicode form, so
+// punycode-encoded names match their Unicode spelling.
+type URLDomainMatcher struct {
+	Domain string
+}
+
+func newURLDomainMatcher(domain string) (*URLDomainMatcher, error) {
+	normalized, err := normalizeHost(domain)
+	if err != nil || normalized == "" {
+		return nil, fmt.Errorf("can't parse domain %q: %v", domain, err)
+	}
+
+	return &URLDomainMatcher{Domain: normalized}, nil
+}
+
+// Match returns URLs of message text, which belong to domain.
+func (matcher *URLDomainMatcher) Match(message *Message) []string {
+	urls := []string{}
+	for _, raw := range extractURLs(message.Text()) {
+		address := raw
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+
+		parsed, err := url.Parse(address)
+		if err != nil {
+			continue
+		}
+
+		host, err := normalizeHost(parsed.Hostname())
+		if err != nil {
+			continue
+		}
+
+		if host == matcher.Domain ||
+			strings.HasSuffix(host, "."+matcher.Domain) {
+			urls = append(urls, raw)
+		}
+	}
+
+	return urls
+}
+
+// normalizeHost returns host in lower case without trailing dot, with
+// punycode labels, starting with xn--, decoded.
+func normalizeHost(host string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(host), "."), ".")
+
+	for index, label := range labels {
+		if !strings.HasPrefix(label, "xn--") {
+			continue
+		}
+
+		decoded, err := decodePunycode(strings.TrimPrefix(label, "xn--"))
+		if err != nil {
+			return "", err
+		}
+
+		labels[index] = strings.ToLower(decoded)
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// Punycode parameters, see RFC 3492.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// decodePunycode decodes single punycode label without xn-- prefix. Label,
+// which decodes into something, which is not a plausible domain label, is
+// rejected, see checkPunycodeLabel.
+func decodePunycode(input string) (string, error) {
+	var (
+		output = []rune{}
+		pos    = 0
+	)
+
+	if basic := strings.LastIndex(input, "-"); basic >= 0 {
+		output = []rune(input[:basic])
+		pos = basic + 1
+	}
+
+	var (
+		n    = punycodeInitialN
+		i    = 0
+		bias = punycodeInitialBias
+	)
+
+	for pos < len(input) {
+		previous := i
+
+		for weight, k := 1, punycodeBase; ; k += punycodeBase {
+			if pos >= len(input) {
+				return "", fmt.Errorf("invalid punycode %q", input)
+			}
+
+			digit := punycodeDigit(input[pos])
+			pos++
+
+			if digit < 0 {
+				return "", fmt.Errorf("invalid punycode %q", input)
+			}
+
+			i += digit * weight
+
+			threshold := k - bias
+			switch {
+			case threshold < punycodeTMin:
+				threshold = punycodeTMin
+
+			case threshold > punycodeTMax:
+				threshold = punycodeTMax
+			}
+
+			if digit < threshold {
+				break
+			}
+
+			weight *= punycodeBase - threshold
+
+			if i > 0x10ffff*len(input) || weight > 0x10ffff*len(input) {
+				return "", fmt.Errorf("invalid punycode %q", input)
+			}
+		}
+
+		bias = adaptPunycodeBias(i-previous, len(output)+1, previous == 0)
+
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+
+		if n > 0x10ffff {
+			return "", fmt.Errorf("invalid punycode %q", input)
+		}
+
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+
+	err := checkPunycodeLabel(input, string(output))
+	if err != nil {
+		return "", fmt.Errorf("invalid punycode %q: %s", input, err)
+	}
+
+	return string(output), nil
+}
+
+// checkPunycodeLabel checks that decoded label is one, which could be
+// registered: it contains non-ASCII characters, is encoded back into the
+// same punycode, consists of letters, digits and hyphens only and doesn't mix
+// scripts, except of Han with Japanese kana. Random strings of punycode
+// digits are valid punycode, but usually decode into mix of scripts.
+func checkPunycodeLabel(input string, decoded string) error {
+	ascii := true
+	for _, char := range decoded {
+		if char >= 0x80 {
+			ascii = false
+		}
+
+		if char != '-' && !unicode.IsLetter(char) && !unicode.IsDigit(char) &&
+			!unicode.IsMark(char) {
+			return fmt.Errorf("disallowed character %U", char)
+		}
+	}
+
+	if ascii {
+		return fmt.Errorf("no non-ASCII characters")
+	}
+
+	if encodePunycode(decoded) != input {
+		return fmt.Errorf("not in canonical form")
+	}
+
+	scripts := map[string]bool{}
+	for _, char := range decoded {
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" && unicode.Is(table, char) {
+				scripts[name] = true
+			}
+		}
+	}
+
+	if scripts["Han"] {
+		delete(scripts, "Hiragana")
+		delete(scripts, "Katakana")
+	}
+
+	if len(scripts) > 1 {
+		return fmt.Errorf("mixed scripts")
+	}
+
+	return nil
+}
+
+// encodePunycode encodes label into punycode without xn-- prefix.
+func encodePunycode(input string) string {
+	var (
+		runes  = []rune(input)
+		output = []byte{}
+	)
+
+	for _, char := range runes {
+		if char < 0x80 {
+			output = append(output, byte(char))
+		}
+	}
+
+	var (
+		basic   = len(output)
+		handled = basic
+		n       = punycodeInitialN
+		delta   = 0
+		bias    = punycodeInitialBias
+	)
+
+	if basic > 0 {
+		output = append(output, '-')
+	}
+
+	for handled < len(runes) {
+		next := int(unicode.MaxRune) + 1
+		for _, char := range runes {
+			if int(char) >= n && int(char) < next {
+				next = int(char)
+			}
+		}
+
+		delta += (next - n) * (handled + 1)
+		n = next
+
+		for _, char := range runes {
+			if int(char) < n {
+				delta++
+			}
+
+			if int(char) != n {
+				continue
+			}
+
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				threshold := clamp(k-bias, punycodeTMin, punycodeTMax)
+				if q < threshold {
+					break
+				}
+
+				output = append(output, punycodeChar(
+					threshold+(q-threshold)%(punycodeBase-threshold),
+				))
+
+				q = (q - threshold) / (punycodeBase - threshold)
+			}
+
+			output = append(output, punycodeChar(q))
+
+			bias = adaptPunycodeBias(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(output)
+}
+
+func adaptPunycodeBias(delta, points int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+
+	delta += delta / points
+
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeChar(digit int) byte {
+	if digit < 26 {
+		return byte('a' + digit)
+	}
+
+	return byte('0' + digit - 26)
+}
+
+func punycodeDigit(char byte) int {
+	switch {
+	case char >= '0' && char <= '9':
+		return int(char-'0') + 26
+
+	case char >= 'a' && char <= 'z':
+		return int(char - 'a')
+
+	case char >= 'A' && char <= 'Z':
+		return int(char - 'A')
+	}
+
+	return -1
+}
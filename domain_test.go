@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodePunycode(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"mnchen-3ya", "münchen"},
+		{"80ak6aa92e", "аррӏе"},
+		{"d1acufc", "домен"},
+		{"wgv71a119e", "日本語"},
+		{"eckwd4c7c", "ドメイン"},
+		{"3e0b707e", "한국"},
+		{"bcher-kva", "bücher"},
+	}
+
+	for _, test := range tests {
+		got, err := decodePunycode(test.label)
+		if err != nil {
+			t.Errorf("decodePunycode(%q): %s", test.label, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf(
+				"decodePunycode(%q) = %q, want %q",
+				test.label, got, test.want,
+			)
+		}
+
+		encoded := encodePunycode(got)
+		if encoded != test.label {
+			t.Errorf(
+				"encodePunycode(%q) = %q, want %q",
+				got, encoded, test.label,
+			)
+		}
+	}
+}
+
+func TestDecodePunycodeInvalid(t *testing.T) {
+	for _, label := range []string{
+		"zzzzzzzzzzzzzzzzzzz",
+		"",
+		"abc-",
+		"a",
+		"mnchen-3ya!",
+		"mnchen-3y",
+		"99999999999999",
+		"a-",
+	} {
+		decoded, err := decodePunycode(label)
+		if err == nil {
+			t.Errorf("decodePunycode(%q) = %q, want error", label, decoded)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"Example.COM.", "example.com"},
+		{"xn--mnchen-3ya.de", "münchen.de"},
+		{"XN--MNCHEN-3YA.de", "münchen.de"},
+	}
+
+	for _, test := range tests {
+		got, err := normalizeHost(test.host)
+		if err != nil || got != test.want {
+			t.Errorf(
+				"normalizeHost(%q) = %q, %v, want %q",
+				test.host, got, err, test.want,
+			)
+		}
+	}
+
+	_, err := normalizeHost("xn--zzzzzzzzzzzzzzzzzzz.com")
+	if err == nil {
+		t.Errorf("invalid punycode label should fail")
+	}
+}
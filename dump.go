@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+const dumpMessageLength = 40
+
+// dumpParsed prints parsed header fields of every history record without
+// any filtering.
+func dumpParsed(args map[string]interface{}) error {
+	return walkHistory(
+		args,
+		func(file HistoryFile, reader io.Reader) error {
+			return readMessages(
+				file.Location(),
+				reader,
+				func(message *Message) error {
+					header := message.Header
+
+					fmt.Fprintf(
+						stdout,
+						"%s: direction=%s utc=%s local=%s length=%d message=%q\n",
+						file.Name,
+						header.Direction,
+						header.Time.UTC().Format(time.RFC3339),
+						header.Time.Format(time.RFC3339),
+						header.Length,
+						truncate(header.Message, dumpMessageLength),
+					)
+
+					return nil
+				},
+			)
+		},
+	)
+}
+
+func truncate(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	return string([]rune(text)[:length]) + "..."
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// earliestBuffer keeps at most limit messages with the earliest time: when
+// buffer overflows, the latest message is dropped. Messages with the same
+// time are kept in order they were added.
+type earliestBuffer struct {
+	limit    int
+	added    int
+	messages earliestQueue
+}
+
+// earliestMessage is a buffered message with its position in input, which
+// breaks ties between messages with the same time.
+type earliestMessage struct {
+	scoredMessage
+	index int
+}
+
+// earliestQueue is a heap of buffered messages with the latest message on
+// top.
+type earliestQueue []earliestMessage
+
+func newEarliestBuffer(limit int) *earliestBuffer {
+	return &earliestBuffer{
+		limit: limit,
+	}
+}
+
+// Add adds message of specified file to the buffer.
+func (buffer *earliestBuffer) Add(file HistoryFile, message *Message) {
+	heap.Push(&buffer.messages, earliestMessage{
+		scoredMessage: scoredMessage{file: file, message: message},
+		index:         buffer.added,
+	})
+
+	buffer.added++
+
+	if len(buffer.messages) > buffer.limit {
+		heap.Pop(&buffer.messages)
+	}
+}
+
+// Flush passes buffered messages to output in chronological order.
+func (buffer *earliestBuffer) Flush(
+	output func(file HistoryFile, message *Message) error,
+) error {
+	messages := buffer.messages
+	buffer.messages = nil
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[j].later(messages[i])
+	})
+
+	for _, message := range messages {
+		err := output(message.file, message.message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// later reports whether message goes after other in chronological order.
+func (message earliestMessage) later(other earliestMessage) bool {
+	var (
+		moment = message.message.Header.Time
+		then   = other.message.Header.Time
+	)
+
+	if moment.Equal(then) {
+		return message.index > other.index
+	}
+
+	return moment.After(then)
+}
+
+func (queue earliestQueue) Len() int {
+	return len(queue)
+}
+
+func (queue earliestQueue) Less(i, j int) bool {
+	return queue[i].later(queue[j])
+}
+
+func (queue earliestQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+}
+
+func (queue *earliestQueue) Push(value interface{}) {
+	*queue = append(*queue, value.(earliestMessage))
+}
+
+func (queue *earliestQueue) Pop() interface{} {
+	var (
+		old     = *queue
+		message = old[len(old)-1]
+	)
+
+	*queue = old[:len(old)-1]
+
+	return message
+}
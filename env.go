@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+)
+
+// defaultHistoryPath is a directory, which is searched, if --path is not
+// specified.
+const defaultHistoryPath = "$HOME/.mcabber/history"
+
+// envDefaults are default values of options, which contain environment
+// variables. They are not docopt defaults, so values, specified in command
+// line, can be told apart from them and are always used literally.
+var envDefaults = map[string]string{
+	"--index-dir":    "$HOME/.cache/mcabber-history/index",
+	"--read-markers": "$HOME/.mcabber/read_markers",
+	"--jid-map":      "$HOME/.mcabber/jids",
+}
+
+// setEnvDefaults sets default values of options, which are not specified in
+// command line, expanding environment variables in them, like $HOME, unless
+// expand is false.
+func setEnvDefaults(args map[string]interface{}, expand bool) {
+	value := func(value string) string {
+		if expand {
+			return os.ExpandEnv(value)
+		}
+
+		return value
+	}
+
+	for key, fallback := range envDefaults {
+		if args[key] == nil {
+			args[key] = value(fallback)
+		}
+	}
+
+	if paths, _ := args["--path"].([]string); len(paths) == 0 {
+		args["--path"] = []string{value(defaultHistoryPath)}
+	}
+}
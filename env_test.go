@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetEnvDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		argv   []string
+		key    string
+		want   string
+		expand bool
+	}{
+		{
+			"expanded path default",
+			[]string{"-S", "work"},
+			"--path", "$HOME/.mcabber/history", true,
+		},
+		{
+			"literal path default",
+			[]string{"--no-expand-env", "-S", "work"},
+			"--path", "$HOME/.mcabber/history", false,
+		},
+		{
+			"expanded option default",
+			[]string{"-S", "work"},
+			"--jid-map", "$HOME/.mcabber/jids", true,
+		},
+		{
+			"literal option default",
+			[]string{"--no-expand-env", "-S", "work"},
+			"--jid-map", "$HOME/.mcabber/jids", false,
+		},
+		{
+			"value equal to default",
+			[]string{"--jid-map", "$HOME/.mcabber/jids", "-S", "work"},
+			"--jid-map", "$HOME/.mcabber/jids", false,
+		},
+		{
+			"path equal to default",
+			[]string{"--path", "$HOME/.mcabber/history", "-S", "work"},
+			"--path", "$HOME/.mcabber/history", false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := parseTestArgs(t, test.argv...)
+
+			want := test.want
+			if test.expand {
+				want = os.ExpandEnv(want)
+			}
+
+			got, ok := args[test.key].(string)
+			if paths, isList := args[test.key].([]string); isList {
+				got, ok = strings.Join(paths, ","), true
+			}
+
+			if !ok || got != want {
+				t.Errorf("%s = %q, want %q", test.key, args[test.key], want)
+			}
+		})
+	}
+}
+
+func TestPathWithDollar(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history$HOME")
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> deploy failed",
+	)
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--plain", "work", "deploy",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "deploy failed") {
+		t.Errorf("history in %q is not searched, got:\n%s", dir, output)
+	}
+}
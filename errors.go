@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// ErrorCollector applies error policy to errors in single history files,
+// like files, which can't be opened, or malformed lines: either search is
+// aborted on the first such error, or errors are reported and counted, so
+// other files are still searched and search fails only after all of them.
+// Errors can be collected from several goroutines.
+type ErrorCollector struct {
+	FailFast bool
+
+	mutex sync.Mutex
+	count int
+}
+
+// fileErrors collects errors in history files according to --fail-fast.
+var fileErrors ErrorCollector
+
+// setupErrors sets policy of handling errors in history files, specified in
+// command line arguments.
+func setupErrors(args map[string]interface{}) error {
+	if args["--fail-fast"].(bool) && args["--collect-errors"].(bool) {
+		return fmt.Errorf("--fail-fast can't be used with --collect-errors")
+	}
+
+	strict = args["--strict"].(bool)
+
+	fileErrors = ErrorCollector{
+		FailFast: args["--fail-fast"].(bool) || strict,
+	}
+
+	skipErrors = args["--skip-errors"].(bool) || args["--robust-resync"].(bool)
+	robustResync = args["--robust-resync"].(bool)
+
+	return nil
+}
+
+// Collect returns err as is, if collector fails fast. Otherwise err is
+// reported and counted and nil is returned, so caller proceeds with next
+// history file.
+func (collector *ErrorCollector) Collect(err error) error {
+	if collector.FailFast {
+		return err
+	}
+
+	slog.Warn(err.Error())
+
+	collector.mutex.Lock()
+	collector.count++
+	collector.mutex.Unlock()
+
+	return nil
+}
+
+// Err returns error, describing collected errors, or nil, if there were no
+// errors.
+func (collector *ErrorCollector) Err() error {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	if collector.count == 0 {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%d errors in history files occurred, see warnings above",
+		collector.count,
+	)
+}
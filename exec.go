@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// Executor passes matching messages to external shell command.
+type Executor struct {
+	Command string
+
+	// Batch makes executor to run command only once, passing all messages
+	// on stdin, separated by blank lines.
+	Batch bool
+
+	// Abort makes executor to return error if command fails; otherwise
+	// error is only logged.
+	Abort bool
+
+	batch bytes.Buffer
+}
+
+// Run runs command for specified message or adds message to the batch.
+func (executor *Executor) Run(file HistoryFile, message *Message) error {
+	if executor.Batch {
+		if executor.batch.Len() > 0 {
+			executor.batch.WriteString("\n")
+		}
+
+		executor.batch.WriteString(message.Text() + "\n")
+
+		return nil
+	}
+
+	return executor.run(
+		[]byte(message.Text()+"\n"),
+		"MCH_CHANNEL="+file.Channel(),
+		"MCH_TIME="+message.Header.Time.Format(time.RFC3339),
+		"MCH_DIR="+string(message.Header.Direction),
+	)
+}
+
+// Flush runs command for collected batch of messages, if any.
+func (executor *Executor) Flush() error {
+	if !executor.Batch || executor.batch.Len() == 0 {
+		return nil
+	}
+
+	return executor.run(executor.batch.Bytes())
+}
+
+func (executor *Executor) run(stdin []byte, env ...string) error {
+	command := exec.Command("sh", "-c", executor.Command)
+	command.Stdin = bytes.NewReader(stdin)
+	command.Stdout = stdout
+	command.Stderr = os.Stderr
+	command.Env = append(os.Environ(), env...)
+
+	err := command.Run()
+	if err == nil {
+		return nil
+	}
+
+	err = ser.Errorf(err, "command %q failed", executor.Command)
+	if executor.Abort {
+		return err
+	}
+
+	slog.Error(err.Error())
+
+	return nil
+}
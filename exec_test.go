@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExecOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> deploy failed",
+	)
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h",
+		"--exec", `echo "$MCH_CHANNEL: $(cat)"`, "work",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "work: <alice> deploy failed\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("output of command %q is missing, got:\n%s", want, output)
+	}
+}
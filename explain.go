@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MatchExplainer finds out which filter terms matched message and where.
+type MatchExplainer struct {
+	terms      []string
+	groups     []int
+	expression *regexp.Regexp
+}
+
+// newMatchExplainer creates explainer for filter, which is built from
+// specified terms, by wrapping every term into capturing group.
+func newMatchExplainer(
+	terms []string,
+	exact bool,
+) (*MatchExplainer, error) {
+	explainer := &MatchExplainer{
+		terms: terms,
+	}
+
+	var (
+		wrapped = []string{}
+		group   = 1
+	)
+
+	for _, term := range terms {
+		expression, err := regexp.Compile(term)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't explain matches of term %q: %s",
+				term, err,
+			)
+		}
+
+		wrapped = append(wrapped, "("+term+")")
+
+		explainer.groups = append(explainer.groups, group)
+
+		group += 1 + expression.NumSubexp()
+	}
+
+	expression := `(?si)` + strings.Join(wrapped, `.*`)
+	if exact {
+		expression = `(?si)\A(?:` + strings.Join(wrapped, `.*`) + `)\z`
+	}
+
+	var err error
+
+	explainer.expression, err = regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"can't compile explain regexp %q: %s",
+			expression, err,
+		)
+	}
+
+	return explainer, nil
+}
+
+// Explain returns annotation with every term, its matched text and offsets
+// of it in specified text, like `"deploy" matched "Deploy" at 4-10`.
+func (explainer *MatchExplainer) Explain(text string) string {
+	explanations := []string{}
+
+	for _, match := range explainer.expression.FindAllStringSubmatchIndex(
+		text,
+		-1,
+	) {
+		for index, term := range explainer.terms {
+			var (
+				group = explainer.groups[index]
+				start = match[group*2]
+				end   = match[group*2+1]
+			)
+
+			if start < 0 {
+				continue
+			}
+
+			explanations = append(
+				explanations,
+				fmt.Sprintf(
+					"%q matched %q at %d-%d",
+					term, text[start:end], start, end,
+				),
+			)
+		}
+	}
+
+	return strings.Join(explanations, ", ")
+}
@@ -0,0 +1,74 @@
+package main
+
+// fairBuffer keeps at most limit messages, distributed between channels as
+// evenly as possible: when buffer overflows, last message of channel with
+// most buffered messages is dropped.
+type fairBuffer struct {
+	limit    int
+	total    int
+	channels []string
+	buffers  map[string][]scoredMessage
+}
+
+func newFairBuffer(limit int) *fairBuffer {
+	return &fairBuffer{
+		limit:   limit,
+		buffers: map[string][]scoredMessage{},
+	}
+}
+
+// Add adds message of specified file to the buffer.
+func (buffer *fairBuffer) Add(file HistoryFile, message *Message) {
+	channel := file.Label + "/" + file.Channel()
+
+	if _, ok := buffer.buffers[channel]; !ok {
+		buffer.channels = append(buffer.channels, channel)
+	}
+
+	buffer.buffers[channel] = append(
+		buffer.buffers[channel],
+		scoredMessage{file: file, message: message},
+	)
+
+	buffer.total++
+
+	if buffer.total <= buffer.limit {
+		return
+	}
+
+	largest := ""
+	for _, channel := range buffer.channels {
+		if len(buffer.buffers[channel]) > len(buffer.buffers[largest]) {
+			largest = channel
+		}
+	}
+
+	messages := buffer.buffers[largest]
+
+	buffer.buffers[largest] = messages[:len(messages)-1]
+	buffer.total--
+}
+
+// Flush passes buffered messages to output, taking one message from every
+// channel in turn.
+func (buffer *fairBuffer) Flush(
+	output func(file HistoryFile, message *Message) error,
+) error {
+	for index := 0; buffer.total > 0; index++ {
+		for _, channel := range buffer.channels {
+			messages := buffer.buffers[channel]
+			if index >= len(messages) {
+				continue
+			}
+
+			buffer.total--
+
+			err := output(messages[index].file, messages[index].message)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
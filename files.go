@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+)
+
+// FilesReport lists history files with matching messages in order they were
+// searched.
+type FilesReport struct {
+	// Null makes report to terminate file names with NUL byte instead of
+	// newline.
+	Null bool
+
+	files []string
+	seen  map[string]bool
+}
+
+func (report *FilesReport) Add(file HistoryFile, message *Message) error {
+	if report.seen == nil {
+		report.seen = map[string]bool{}
+	}
+
+	if !report.seen[file.Name] {
+		report.seen[file.Name] = true
+		report.files = append(report.files, file.Name)
+	}
+
+	return nil
+}
+
+func (report *FilesReport) Print(asJSON bool) error {
+	if asJSON {
+		return printJSON(report.files)
+	}
+
+	terminator := "\n"
+	if report.Null {
+		terminator = "\x00"
+	}
+
+	for _, file := range report.files {
+		_, err := fmt.Fprint(stdout, file, terminator)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
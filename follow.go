@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// followInterval is an interval of checking history files for new messages
+// in --follow mode.
+const followInterval = time.Second
+
+// followedFile is a history file, which is read by --follow as it grows.
+type followedFile struct {
+	file   HistoryFile
+	offset int64
+}
+
+// follow waits for messages, which are appended to history files of
+// searched channels after search is finished, and searches them too, until
+// interrupted or until --limit is reached. Only current history files are
+// followed, not rotated ones.
+func follow(args map[string]interface{}, searcher *Searcher) error {
+	if _, ok := args["--archive"].(string); ok {
+		return fmt.Errorf("--follow can't be used with --archive")
+	}
+
+	if isStdin(args) {
+		return fmt.Errorf("--follow can't be used with history from stdin")
+	}
+
+	selector, err := newChannelSelector(args)
+	if err != nil {
+		return err
+	}
+
+	files, err := listHistory(args, selector)
+	if err != nil {
+		return err
+	}
+
+	followed := []*followedFile{}
+	for _, file := range files {
+		if file.Rotation() != 0 {
+			continue
+		}
+
+		stat, err := os.Stat(file.Name)
+		if err != nil {
+			return ser.Errorf(
+				err,
+				"can't stat history file %q",
+				file.Name,
+			)
+		}
+
+		followed = append(followed, &followedFile{
+			file:   file,
+			offset: stat.Size(),
+		})
+	}
+
+	if value, ok := args["--rate-limit"].(string); ok {
+		searcher.RateLimit, err = parseRateLimit(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for !searcher.isLimitReached() {
+		time.Sleep(followInterval)
+
+		for _, file := range followed {
+			err := file.read(searcher)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := searcher.Flush()
+		if err != nil {
+			return err
+		}
+
+		if searcher.RateLimit != nil {
+			if dropped := searcher.RateLimit.Dropped(); dropped > 0 {
+				searcher.printDropped(dropped)
+			}
+		}
+	}
+
+	return nil
+}
+
+// read searches complete messages, appended to file since previous read.
+// Message, which is not completely written yet, is read next time.
+func (followed *followedFile) read(searcher *Searcher) error {
+	handle, err := os.Open(followed.file.Name)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't open history file %q",
+			followed.file.Name,
+		)
+	}
+
+	defer handle.Close()
+
+	stat, err := handle.Stat()
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't stat history file %q",
+			followed.file.Name,
+		)
+	}
+
+	// File was truncated or replaced, like on rotation.
+	if stat.Size() < followed.offset {
+		followed.offset = 0
+	}
+
+	if stat.Size() == followed.offset {
+		return nil
+	}
+
+	data := make([]byte, stat.Size()-followed.offset)
+
+	_, err = handle.ReadAt(data, followed.offset)
+	if err != nil && err != io.EOF {
+		return ser.Errorf(
+			err,
+			"can't read history file %q",
+			followed.file.Name,
+		)
+	}
+
+	var (
+		messages = []*Message{}
+		consumed = int64(0)
+	)
+
+	err = readMessages(
+		followed.file.Name,
+		bytes.NewReader(data),
+		func(message *Message) error {
+			consumed = message.Offset + message.Size
+			message.Offset += followed.offset
+
+			messages = append(messages, message)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	followed.offset += consumed
+
+	return searcher.SearchMessages(followed.file, messages)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/reconquest/ser-go"
+)
+
+// parseTemplate compiles output template, specified either inline by
+// --format or by --template-file. Template is executed for every matching
+// message with JSONMessage as data, so same fields are available, like
+// {{.Time}}, {{.Nick}} or {{.Text}}. Nil is returned if neither of them is
+// specified.
+func parseTemplate(args map[string]interface{}) (*template.Template, error) {
+	var (
+		format, inline = args["--format"].(string)
+		path, fromFile = args["--template-file"].(string)
+	)
+
+	switch {
+	case inline && fromFile:
+		return nil, fmt.Errorf(
+			"--format and --template-file can't be used together",
+		)
+
+	case fromFile:
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't read template file %q",
+				path,
+			)
+		}
+
+		format = string(contents)
+
+	case !inline:
+		return nil, nil
+	}
+
+	compiled, err := template.New("format").Parse(format)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't compile output template",
+		)
+	}
+
+	return compiled, nil
+}
+
+// printTemplate prints message, rendered by output template. Newline is
+// added, if template doesn't end with it.
+func (searcher *Searcher) printTemplate(
+	file HistoryFile,
+	message *Message,
+) error {
+	buffer := bytes.Buffer{}
+
+	err := searcher.Template.Execute(&buffer, newJSONMessage(file, message))
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't render message at %s:%d",
+			file.Location(),
+			message.Offset,
+		)
+	}
+
+	if searcher.OutputNull {
+		text := strings.TrimSuffix(buffer.String(), "\n")
+
+		buffer.Reset()
+		buffer.WriteString(text + "\x00")
+	} else if !strings.HasSuffix(buffer.String(), "\n") {
+		buffer.WriteString("\n")
+	}
+
+	_, err = stdout.Write(buffer.Bytes())
+
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+)
+
+// formattingSequences matches sequences, which are stripped by
+// --strip-formatting:
+//   - ANSI CSI sequences, like ESC [ 1 ; 31 m;
+//   - other two-byte ANSI escape sequences;
+//   - IRC color codes: 0x03 with optional foreground and background
+//     numbers, and 0x04 with optional hex colors;
+//   - IRC bold (0x02), monospace (0x11), reverse (0x16), italic (0x1d),
+//     strikethrough (0x1e), underline (0x1f) and reset (0x0f) codes.
+var formattingSequences = regexp.MustCompile(
+	`\x1b\[[0-?]*[ -/]*[@-~]` +
+		`|\x1b[@-_]` +
+		`|\x03(\d{1,2}(,\d{1,2})?)?` +
+		`|\x04([0-9a-fA-F]{6}(,[0-9a-fA-F]{6})?)?` +
+		`|[\x02\x0f\x11\x16\x1d\x1e\x1f]`,
+)
+
+func stripFormatting(text string) string {
+	return formattingSequences.ReplaceAllString(text, "")
+}
+
+// stripMessageFormatting returns copy of message with formatting sequences
+// removed from header message and body lines.
+func stripMessageFormatting(message *Message) *Message {
+	header := *message.Header
+	header.Message = stripFormatting(header.Message)
+
+	stripped := *message
+	stripped.Header = &header
+	stripped.Body = nil
+
+	for _, line := range message.Body {
+		stripped.Body = append(stripped.Body, stripFormatting(line))
+	}
+
+	return &stripped
+}
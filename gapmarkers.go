@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GapMarkers describes marker lines, printed between consecutive messages of
+// the same channel, which are written more than Threshold apart.
+type GapMarkers struct {
+	Threshold time.Duration
+
+	// Format is a marker line, where %s is replaced by gap, like 3h 20m.
+	Format string
+}
+
+// Marker returns marker line for gap between messages or empty string, if
+// gap is not larger than threshold.
+func (markers *GapMarkers) Marker(gap time.Duration) string {
+	if gap <= markers.Threshold {
+		return ""
+	}
+
+	return strings.Replace(markers.Format, "%s", formatGap(gap), 1)
+}
+
+// formatGap formats duration with two largest units of days, hours, minutes
+// and seconds, like 2d 4h or 3h 20m.
+func formatGap(gap time.Duration) string {
+	units := []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+
+	parts := []string{}
+	for _, unit := range units {
+		if len(parts) == 2 {
+			break
+		}
+
+		count := gap / unit.size
+		if count == 0 && len(parts) == 0 {
+			continue
+		}
+
+		gap -= count * unit.size
+
+		if count > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", count, unit.suffix))
+		} else {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		return "0s"
+	}
+
+	return strings.Join(parts, " ")
+}
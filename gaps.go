@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// GapsReport finds largest silence gaps between matching messages and
+// densest bursts of them.
+type GapsReport struct {
+	Count       int
+	BurstWindow time.Duration
+
+	times []time.Time
+}
+
+// Gap is a period without matching messages.
+type Gap struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	Seconds  float64   `json:"seconds"`
+}
+
+// Burst is a period of specified length with many matching messages.
+type Burst struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Messages int       `json:"messages"`
+}
+
+func (report *GapsReport) Add(file HistoryFile, message *Message) error {
+	report.times = append(report.times, message.Header.Time)
+
+	return nil
+}
+
+func (report *GapsReport) Print(asJSON bool) error {
+	sort.Slice(report.times, func(i, j int) bool {
+		return report.times[i].Before(report.times[j])
+	})
+
+	var (
+		gaps   = report.gaps()
+		bursts = report.bursts()
+	)
+
+	if asJSON {
+		return printJSON(map[string]interface{}{
+			"gaps":   gaps,
+			"bursts": bursts,
+		})
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "largest gaps:")
+	fmt.Fprintln(writer, "start\tend\tduration")
+	for _, gap := range gaps {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\n",
+			gap.Start.Format(time.ANSIC),
+			gap.End.Format(time.ANSIC),
+			gap.Duration,
+		)
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "densest bursts (%s):\n", report.BurstWindow)
+	fmt.Fprintln(writer, "start\tend\tmessages")
+	for _, burst := range bursts {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%d\n",
+			burst.Start.Format(time.ANSIC),
+			burst.End.Format(time.ANSIC),
+			burst.Messages,
+		)
+	}
+
+	return writer.Flush()
+}
+
+func (report *GapsReport) gaps() []Gap {
+	gaps := []Gap{}
+	for i := 1; i < len(report.times); i++ {
+		duration := report.times[i].Sub(report.times[i-1])
+
+		gaps = append(gaps, Gap{
+			Start:    report.times[i-1],
+			End:      report.times[i],
+			Duration: duration.String(),
+			Seconds:  duration.Seconds(),
+		})
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].Seconds > gaps[j].Seconds
+	})
+
+	if len(gaps) > report.Count {
+		gaps = gaps[:report.Count]
+	}
+
+	return gaps
+}
+
+// bursts returns non-overlapping windows, starting at matching message, with
+// most messages in them.
+func (report *GapsReport) bursts() []Burst {
+	candidates := []Burst{}
+
+	end := 0
+	for start := range report.times {
+		limit := report.times[start].Add(report.BurstWindow)
+
+		for end < len(report.times) && !report.times[end].After(limit) {
+			end++
+		}
+
+		candidates = append(candidates, Burst{
+			Start:    report.times[start],
+			End:      report.times[end-1],
+			Messages: end - start,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Messages > candidates[j].Messages
+	})
+
+	bursts := []Burst{}
+	for _, candidate := range candidates {
+		if len(bursts) >= report.Count {
+			break
+		}
+
+		overlaps := false
+		for _, burst := range bursts {
+			if !candidate.Start.After(burst.End) &&
+				!burst.Start.After(candidate.End) {
+				overlaps = true
+				break
+			}
+		}
+
+		if !overlaps {
+			bursts = append(bursts, candidate)
+		}
+	}
+
+	sort.Slice(bursts, func(i, j int) bool {
+		return bursts[i].Start.Before(bursts[j].Start)
+	})
+
+	return bursts
+}
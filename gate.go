@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// ContextGate keeps matching messages only if some of surrounding messages
+// match secondary pattern too.
+type ContextGate struct {
+	Pattern *regexp.Regexp
+
+	// Window is a time before and after matching message, which surrounding
+	// messages are looked up within; it's used if Messages is zero.
+	Window time.Duration
+
+	// Messages is a number of messages before and after matching message,
+	// which are looked up.
+	Messages int
+}
+
+// Filter unmarks matching messages, which have no surrounding messages,
+// matching Pattern. Messages should be all messages of single history file
+// in order they are written.
+func (gate *ContextGate) Filter(messages []*Message, matches []bool) {
+	for index, matched := range matches {
+		if !matched {
+			continue
+		}
+
+		matches[index] = gate.found(messages, index, -1) ||
+			gate.found(messages, index, 1)
+	}
+}
+
+// found reports whether message before or after one at specified index,
+// depending on direction, matches Pattern within window.
+func (gate *ContextGate) found(
+	messages []*Message,
+	index int,
+	direction int,
+) bool {
+	moment := messages[index].Header.Time
+
+	for distance := 1; ; distance++ {
+		position := index + direction*distance
+		if position < 0 || position >= len(messages) {
+			return false
+		}
+
+		if gate.Messages > 0 && distance > gate.Messages {
+			return false
+		}
+
+		gap := messages[position].Header.Time.Sub(moment)
+		if gap < 0 {
+			gap = -gap
+		}
+
+		if gate.Messages == 0 && gap > gate.Window {
+			return false
+		}
+
+		if gate.Pattern.MatchString(stripFormatting(messages[position].Content())) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Highlighter highlights occurrences of filter terms in printed messages.
+type Highlighter struct {
+	expression *regexp.Regexp
+
+	// Max is a maximum number of highlighted occurrences per message; zero
+	// means no limit.
+	Max int
+}
+
+// newHighlighter creates highlighter for filter terms, or returns nil, if
+// there are no terms.
+func newHighlighter(terms []string, max int) (*Highlighter, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	wrapped := []string{}
+	for _, term := range terms {
+		wrapped = append(wrapped, "(?:"+term+")")
+	}
+
+	expression := `(?i)` + strings.Join(wrapped, `|`)
+
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"can't compile highlight regexp %q: %s",
+			expression, err,
+		)
+	}
+
+	return &Highlighter{
+		expression: compiled,
+		Max:        max,
+	}, nil
+}
+
+// Highlight returns copy of message with occurrences of terms highlighted
+// and number of occurrences, which were not highlighted because of Max.
+// Sender nick is never highlighted, so it can still be extracted. Nothing
+// is highlighted, if colors are disabled.
+func (highlighter *Highlighter) Highlight(message *Message) (*Message, int) {
+	if color.NoColor || message.Header.Direction == DirectionInfo {
+		return message, 0
+	}
+
+	var (
+		highlighted = 0
+		hidden      = 0
+		style       = color.New(color.FgRed, color.Bold)
+	)
+
+	highlight := func(text string) string {
+		spans := highlighter.expression.FindAllStringIndex(text, -1)
+
+		result := strings.Builder{}
+		end := 0
+
+		for _, span := range spans {
+			if span[0] == span[1] {
+				continue
+			}
+
+			if highlighter.Max > 0 && highlighted >= highlighter.Max {
+				hidden++
+
+				continue
+			}
+
+			result.WriteString(text[end:span[0]])
+			result.WriteString(style.Sprint(text[span[0]:span[1]]))
+
+			end = span[1]
+			highlighted++
+		}
+
+		result.WriteString(text[end:])
+
+		return result.String()
+	}
+
+	header := *message.Header
+
+	prefix := ""
+	if nick := extractNick(message); nick != "" {
+		prefix = "<" + nick + ">"
+	}
+
+	header.Message = prefix + highlight(strings.TrimPrefix(header.Message, prefix))
+
+	result := *message
+	result.Header = &header
+	result.Body = nil
+
+	for _, line := range message.Body {
+		result.Body = append(result.Body, highlight(line))
+	}
+
+	return &result, hidden
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reconquest/ser-go"
+)
+
+// HistoryFile describes single history file being processed.
+type HistoryFile struct {
+	// Name is a path to file, used for error reporting.
+	Name string
+
+	// Label identifies history directory, which file was found in. It is
+	// empty if only one unlabeled directory is searched.
+	Label string
+
+	// Alias is a short name of file channel, specified by --alias. It is
+	// used instead of channel name in output.
+	Alias string
+
+	// Format is a name of parser, which file should be read with; empty
+	// string means native mcabber format.
+	Format string
+
+	// Archive is a path to archive, which file is read from; Name is a path
+	// of entry within archive then. It is empty for files on disk.
+	Archive string
+}
+
+// rotationSuffix matches suffix of rotated history file, like channel.1.
+var rotationSuffix = regexp.MustCompile(`\.(\d+)$`)
+
+// Channel returns name of channel, which file contains history for. Rotated
+// files, like channel.1, belong to the same channel as original one. Alias
+// of channel is returned, if it is set.
+func (file HistoryFile) Channel() string {
+	if file.Alias != "" {
+		return file.Alias
+	}
+
+	return rotationSuffix.ReplaceAllString(filepath.Base(file.Name), "")
+}
+
+// Location returns name of file for diagnostics: path to file on disk or
+// path to archive along with name of entry, like logs.tar.gz:history/foo.
+func (file HistoryFile) Location() string {
+	if file.Archive != "" {
+		return file.Archive + ":" + file.Name
+	}
+
+	return file.Name
+}
+
+// Rotation returns number of rotated history file: zero for current file,
+// one for channel.1 and so on. Higher number means older file.
+func (file HistoryFile) Rotation() int {
+	match := rotationSuffix.FindStringSubmatch(filepath.Base(file.Name))
+	if match == nil {
+		return 0
+	}
+
+	rotation, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+
+	return rotation
+}
+
+// sortRotated orders rotated files of every channel from oldest to newest,
+// keeping order of channels themselves.
+func sortRotated(files []HistoryFile) {
+	order := map[string]int{}
+	for _, file := range files {
+		key := file.Label + "/" + file.Channel()
+		if _, ok := order[key]; !ok {
+			order[key] = len(order)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		var (
+			left  = order[files[i].Label+"/"+files[i].Channel()]
+			right = order[files[j].Label+"/"+files[j].Channel()]
+		)
+
+		if left != right {
+			return left < right
+		}
+
+		return files[i].Rotation() > files[j].Rotation()
+	})
+}
+
+// HistoryPath is a history directory with label, identifying it in output.
+type HistoryPath struct {
+	Label string
+	Dir   string
+}
+
+// parseHistoryPaths parses --path values in form of [<label>=]<dir>. Label
+// defaults to base name of directory.
+func parseHistoryPaths(values []string) []HistoryPath {
+	var (
+		paths    = []HistoryPath{}
+		labeled  = false
+		multiple = len(values) > 1
+	)
+
+	for _, value := range values {
+		path := HistoryPath{
+			Dir: value,
+		}
+
+		label, dir, ok := strings.Cut(value, "=")
+		if ok && label != "" && !strings.ContainsRune(label, '/') {
+			path.Label = label
+			path.Dir = dir
+			labeled = true
+		}
+
+		paths = append(paths, path)
+	}
+
+	for i := range paths {
+		switch {
+		case paths[i].Label != "":
+			continue
+
+		case multiple || labeled:
+			paths[i].Label = filepath.Base(paths[i].Dir)
+		}
+	}
+
+	return paths
+}
+
+// walkHistory calls handler for every history file of channels, specified in
+// command line arguments, either from history directories or from archive.
+func walkHistory(
+	args map[string]interface{},
+	handler func(file HistoryFile, reader io.Reader) error,
+) error {
+	selector, err := newChannelSelector(args)
+	if err != nil {
+		return err
+	}
+
+	maxSize, err := parseMaxFileSize(args)
+	if err != nil {
+		return err
+	}
+
+	if archive, ok := args["--archive"].(string); ok {
+		return walkArchive(archive, selector, maxSize, handler)
+	}
+
+	if isStdin(args) {
+		return readStdin(args, handler)
+	}
+
+	files, err := listHistory(args, selector)
+	if err != nil {
+		return err
+	}
+
+	mmap := args["--mmap"].(bool)
+
+	for _, file := range files {
+		handle, err := openHistoryReader(file, maxSize, mmap)
+		if err != nil {
+			err = fileErrors.Collect(err)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if handle == nil {
+			continue
+		}
+
+		err = handler(file, handle)
+
+		handle.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeHistory is same as walkHistory, but opens all history files at once
+// and calls handler once for all of them, so they can be read in parallel.
+// History files from archive are not supported.
+func mergeHistory(
+	args map[string]interface{},
+	handler func(files []HistoryFile, readers []io.Reader) error,
+) error {
+	selector, err := newChannelSelector(args)
+	if err != nil {
+		return err
+	}
+
+	maxSize, err := parseMaxFileSize(args)
+	if err != nil {
+		return err
+	}
+
+	listed, err := listHistory(args, selector)
+	if err != nil {
+		return err
+	}
+
+	var (
+		files   = []HistoryFile{}
+		readers = []io.Reader{}
+		mmap    = args["--mmap"].(bool)
+	)
+
+	for _, file := range listed {
+		handle, err := openHistoryReader(file, maxSize, mmap)
+		if err != nil {
+			err = fileErrors.Collect(err)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if handle == nil {
+			continue
+		}
+
+		defer handle.Close()
+
+		files = append(files, file)
+		readers = append(readers, handle)
+	}
+
+	err = handler(files, readers)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// listHistory returns history files of selected channels from history
+// directories, specified in command line arguments, with rotated files of
+// every channel ordered from oldest to newest.
+func listHistory(
+	args map[string]interface{},
+	selector ChannelSelector,
+) ([]HistoryFile, error) {
+	var (
+		paths = parseHistoryPaths(args["--path"].([]string))
+		files = []HistoryFile{}
+		dirs  = []string{}
+		seen  = map[string]bool{}
+		found = map[string]bool{}
+	)
+
+	for _, path := range paths {
+		for _, channel := range selector.Channels {
+			names, err := selector.List(path.Dir, channel)
+			if err != nil {
+				return nil, ser.Errorf(
+					err,
+					"can't obtain files list for %q in %q",
+					channel,
+					path.Dir,
+				)
+			}
+
+			for _, name := range names {
+				found[channel] = true
+
+				if seen[name] {
+					continue
+				}
+
+				seen[name] = true
+
+				files = append(files, HistoryFile{
+					Name:  name,
+					Label: path.Label,
+					Alias: selector.Alias(name),
+				})
+			}
+		}
+
+		dirs = append(dirs, path.Dir)
+	}
+
+	if len(files) == 0 {
+		return nil, ser.Errorf(
+			nil,
+			"no history files found in %q (%q)",
+			strings.Join(dirs, ", "),
+			selector,
+		)
+	}
+
+	sortRotated(files)
+
+	for _, channel := range selector.Channels {
+		if !found[channel] {
+			slog.Warn(
+				"no history files found for channel",
+				"channel", channel,
+				"dirs", strings.Join(dirs, ", "),
+			)
+		}
+	}
+
+	if value, ok := args["--channels-active-since"].(string); ok {
+		now, err := parseAsOf(args)
+		if err != nil {
+			return nil, err
+		}
+
+		since, err := parseRelative(value, now)
+		if err != nil {
+			return nil, err
+		}
+
+		return filterActiveChannels(files, since)
+	}
+
+	return files, nil
+}
+
+// openHistoryFile opens history file for reading. Nil handle is returned
+// for file, which is skipped because it is larger than specified size.
+func openHistoryFile(file HistoryFile, maxSize int64) (*os.File, error) {
+	if maxSize > 0 {
+		stat, err := os.Stat(file.Name)
+		if err == nil && stat.Size() > maxSize {
+			slog.Warn(
+				"skipping history file larger than --max-file-size",
+				"file", file.Name,
+				"size", stat.Size(),
+			)
+
+			stats.SkippedLarge++
+
+			return nil, nil
+		}
+	}
+
+	handle, err := os.Open(file.Name)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open history file %q",
+			file.Name,
+		)
+	}
+
+	stats.Files++
+
+	return handle, nil
+}
+
+// openHistoryReader is same as openHistoryFile, but maps file into memory,
+// if mmap is set.
+func openHistoryReader(
+	file HistoryFile,
+	maxSize int64,
+	mmap bool,
+) (io.ReadCloser, error) {
+	handle, err := openHistoryFile(file, maxSize)
+	if err != nil || handle == nil {
+		return nil, err
+	}
+
+	if mmap {
+		return mapHistoryFile(handle), nil
+	}
+
+	return handle, nil
+}
+
+// ChannelSelector decides which history files belong to searched channels.
+type ChannelSelector struct {
+	// Channels is a list of prefix globs; file names should match any of
+	// them.
+	Channels []string
+
+	// Ignored is a list of file name prefixes to skip.
+	Ignored []string
+
+	// IgnoreCase makes both Channels and Ignored matching case-insensitive.
+	// Channels are compared as plain prefixes in that case, not as globs.
+	IgnoreCase bool
+
+	// Aliases maps short channel names to channels globs, which they are
+	// expanded to.
+	Aliases map[string]string
+}
+
+// newChannelSelector creates selector for channels, specified in command
+// line arguments: comma-separated <channel> list or --channels-file.
+func newChannelSelector(args map[string]interface{}) (ChannelSelector, error) {
+	selector := ChannelSelector{
+		IgnoreCase: args["--ignore-case-channels"].(bool),
+		Aliases:    map[string]string{},
+	}
+
+	for _, value := range args["--alias"].([]string) {
+		alias, channel, ok := strings.Cut(value, "=")
+		if !ok || alias == "" || channel == "" {
+			return selector, fmt.Errorf(
+				"can't parse alias %q: should be in form <alias>=<channel>",
+				value,
+			)
+		}
+
+		selector.Aliases[alias] = channel
+	}
+
+	if channels, ok := args["<channel>"].(string); ok {
+		for _, channel := range strings.Split(channels, ",") {
+			if expanded, ok := selector.Aliases[channel]; ok {
+				channel = expanded
+			}
+
+			selector.Channels = append(selector.Channels, channel)
+		}
+	}
+
+	if args["--all-channels"].(bool) {
+		selector.Channels = []string{""}
+	}
+
+	if path, ok := args["--channels-file"].(string); ok {
+		channels, err := readChannelsFile(path)
+		if err != nil {
+			return selector, err
+		}
+
+		selector.Channels = append(selector.Channels, channels...)
+	}
+
+	if args["--channels-from-stdin"].(bool) {
+		channels, err := readStdinChannels()
+		if err != nil {
+			return selector, err
+		}
+
+		selector.Channels = append(selector.Channels, channels...)
+	}
+
+	if ignored, ok := args["--ignore-channels"].(string); ok && ignored != "" {
+		selector.Ignored = strings.Split(ignored, ",")
+	}
+
+	return selector, nil
+}
+
+// readChannelsFile reads channels list from file: one channel per line,
+// skipping blank lines and comments, starting with #.
+func readChannelsFile(path string) ([]string, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open channels file %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	return readChannels(path, handle)
+}
+
+// stdinChannels is a channels list, read from stdin; selector can be created
+// several times, but stdin can be read only once.
+var stdinChannels []string
+
+func readStdinChannels() ([]string, error) {
+	if stdinChannels != nil {
+		return stdinChannels, nil
+	}
+
+	channels, err := readChannels("stdin", os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	stdinChannels = channels
+
+	return channels, nil
+}
+
+// readChannels reads channels list from reader in format of channels file.
+func readChannels(name string, reader io.Reader) ([]string, error) {
+	channels := []string{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		channels = append(channels, line)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't read channels file %q",
+			name,
+		)
+	}
+
+	return channels, nil
+}
+
+// String returns comma-separated channels list.
+func (selector ChannelSelector) String() string {
+	if len(selector.Channels) == 1 && selector.Channels[0] == "" {
+		return "*"
+	}
+
+	return strings.Join(selector.Channels, ",")
+}
+
+// Alias returns alias of channel, which file with specified name belongs to,
+// or empty string, if there is no such alias.
+func (selector ChannelSelector) Alias(name string) string {
+	aliases := []string{}
+	for alias := range selector.Aliases {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		matched, err := selector.match(name, selector.Aliases[alias])
+		if err == nil && matched {
+			return alias
+		}
+	}
+
+	return ""
+}
+
+// List returns history files from specified directory, which belong to
+// specified channel and are not ignored.
+func (selector ChannelSelector) List(
+	path string,
+	channel string,
+) ([]string, error) {
+	if !selector.IgnoreCase {
+		files, err := filepath.Glob(path + "/" + channel + "*")
+		if err != nil {
+			return nil, err
+		}
+
+		return selector.filter(files, channel)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+
+	return selector.filter(files, channel)
+}
+
+// Match reports whether file with specified name belongs to any of channels.
+func (selector ChannelSelector) Match(name string) (bool, error) {
+	for _, channel := range selector.Channels {
+		matched, err := selector.match(name, channel)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+
+	return false, nil
+}
+
+func (selector ChannelSelector) match(
+	name string,
+	channel string,
+) (bool, error) {
+	name = filepath.Base(name)
+
+	if selector.isIgnored(name) {
+		return false, nil
+	}
+
+	if selector.IgnoreCase {
+		return hasPrefixFold(name, channel), nil
+	}
+
+	return filepath.Match(channel+"*", name)
+}
+
+func (selector ChannelSelector) filter(
+	files []string,
+	channel string,
+) ([]string, error) {
+	selected := []string{}
+	for _, file := range files {
+		matched, err := selector.match(file, channel)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			selected = append(selected, file)
+		}
+	}
+
+	return selected, nil
+}
+
+func (selector ChannelSelector) isIgnored(name string) bool {
+	for _, prefix := range selector.Ignored {
+		if selector.IgnoreCase && hasPrefixFold(name, prefix) {
+			return true
+		}
+
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasPrefixFold(text string, prefix string) bool {
+	return len(text) >= len(prefix) &&
+		strings.EqualFold(text[:len(prefix)], prefix)
+}
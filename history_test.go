@@ -0,0 +1,175 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// listTestHistory returns base names of history files, selected by
+// specified command line arguments.
+func listTestHistory(t *testing.T, argv ...string) []string {
+	t.Helper()
+
+	args := parseTestArgs(t, append([]string{"-S"}, argv...)...)
+
+	selector, err := newChannelSelector(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listHistory(args, selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, file := range files {
+		names = append(names, filepath.Base(file.Name))
+	}
+
+	return names
+}
+
+func TestIgnoreCaseChannels(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"Work@conference.example.com",
+		"work@conference.example.com",
+		"home@conference.example.com",
+	} {
+		writeHistory(t, dir, name,
+			"MR 20240102T10:00:00Z 000 <alice> hello",
+		)
+	}
+
+	tests := []struct {
+		name string
+		argv []string
+		want []string
+	}{
+		{
+			"case-sensitive",
+			[]string{"work"},
+			[]string{"work@conference.example.com"},
+		},
+		{
+			"case-sensitive upper",
+			[]string{"Work"},
+			[]string{"Work@conference.example.com"},
+		},
+		{
+			"case-insensitive",
+			[]string{"--ignore-case-channels", "work"},
+			[]string{
+				"Work@conference.example.com",
+				"work@conference.example.com",
+			},
+		},
+		{
+			"case-insensitive ignored",
+			[]string{
+				"--ignore-case-channels", "--ignore-channels", "WORK",
+				"--all-channels",
+			},
+			[]string{"home@conference.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := listTestHistory(t,
+				append([]string{"--path", dir}, test.argv...)...,
+			)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got files %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRotatedHistoryOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"work", "work.1", "work.2", "work.10",
+		"home", "home.1",
+	} {
+		writeHistory(t, dir, name,
+			"MR 20240102T10:00:00Z 000 <alice> hello",
+		)
+	}
+
+	got := listTestHistory(t, "--path", dir, "--all-channels")
+	want := []string{
+		"home.1", "home",
+		"work.10", "work.2", "work.1", "work",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+
+	got = listTestHistory(t, "--path", dir, "work")
+	want = []string{"work.10", "work.2", "work.1", "work"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+
+	for _, name := range want {
+		file := HistoryFile{Name: filepath.Join(dir, name)}
+		if file.Channel() != "work" {
+			t.Errorf(
+				"channel of %q is %q, want %q",
+				name, file.Channel(), "work",
+			)
+		}
+	}
+}
+
+func TestChannelsFileWithChannels(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"work", "home", "bridge", "other"} {
+		writeHistory(t, dir, name,
+			"MR 20240102T10:00:00Z 000 <alice> hello",
+		)
+	}
+
+	channels := writeHistory(t, t.TempDir(), "channels",
+		"# saved search",
+		"  work  ",
+		"",
+		"home",
+	)
+
+	tests := []struct {
+		name string
+		argv []string
+		want []string
+	}{
+		{
+			"file only",
+			[]string{"--channels-file", channels},
+			[]string{"work", "home"},
+		},
+		{
+			"file and channels",
+			[]string{"--channels-file", channels, "bridge", "hello"},
+			[]string{"bridge", "work", "home"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := listTestHistory(t,
+				append([]string{"--path", dir}, test.argv...)...,
+			)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got files %q, want %q", got, test.want)
+			}
+		})
+	}
+}
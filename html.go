@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body {
+	font-family: sans-serif;
+	background: #f4f4f4;
+	max-width: 50em;
+	margin: 2em auto;
+}
+.message {
+	background: #ffffff;
+	border-radius: 0.8em;
+	padding: 0.5em 0.8em;
+	margin: 0.6em 0;
+	width: fit-content;
+	max-width: 80%;
+	box-shadow: 0 1px 2px rgba(0, 0, 0, 0.15);
+}
+.sent {
+	background: #dcf8c6;
+	margin-left: auto;
+}
+.meta {
+	font-size: 0.8em;
+	color: #888888;
+}
+.nick {
+	font-weight: bold;
+}
+.text {
+	white-space: pre-wrap;
+	margin: 0;
+	font-family: inherit;
+}
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ range .Messages }}
+<div class="message {{ .Class }}">
+	<div class="meta">
+		{{ if .Nick }}<span class="nick" style="color: {{ .NickColor }}">{{ .Nick }}</span>{{ end }}
+		<time datetime="{{ .Time.Format "2006-01-02T15:04:05Z07:00" }}">{{ .Time.Format "Mon Jan _2 15:04:05 2006" }}</time>
+		&middot; {{ .Channel }}
+	</div>
+	<pre class="text">{{ .Text }}</pre>
+</div>
+{{ end }}
+</body>
+</html>
+`))
+
+// HTMLExport writes matching messages into HTML page.
+type HTMLExport struct {
+	Path string
+
+	channels []string
+	messages []htmlMessage
+}
+
+type htmlMessage struct {
+	Channel   string
+	Time      time.Time
+	Class     string
+	Nick      string
+	NickColor template.CSS
+	Text      string
+}
+
+func (export *HTMLExport) Add(file HistoryFile, message *Message) error {
+	var (
+		nick = extractNick(message)
+		text = message.Text()
+	)
+
+	if nick != "" {
+		text = strings.TrimPrefix(text, "<"+nick+"> ")
+	}
+
+	class := "received"
+	if message.Header.Direction == DirectionSend {
+		class = "sent"
+	}
+
+	channel := file.Channel()
+
+	if len(export.channels) == 0 ||
+		export.channels[len(export.channels)-1] != channel {
+		export.channels = append(export.channels, channel)
+	}
+
+	export.messages = append(export.messages, htmlMessage{
+		Channel:   channel,
+		Time:      message.Header.Time,
+		Class:     class,
+		Nick:      nick,
+		NickColor: nickColor(nick),
+		Text:      text,
+	})
+
+	return nil
+}
+
+func (export *HTMLExport) Print(asJSON bool) error {
+	handle, err := os.Create(export.Path)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't create file %q",
+			export.Path,
+		)
+	}
+
+	err = htmlTemplate.Execute(handle, map[string]interface{}{
+		"Title":    strings.Join(unique(export.channels), ", "),
+		"Messages": export.messages,
+	})
+	if err != nil {
+		handle.Close()
+
+		return ser.Errorf(
+			err,
+			"can't write html to %q",
+			export.Path,
+		)
+	}
+
+	return handle.Close()
+}
+
+// nickColor returns stable color for nick, so same nick is always rendered
+// with same color.
+func nickColor(nick string) template.CSS {
+	hash := fnv.New32a()
+	hash.Write([]byte(nick))
+
+	return template.CSS(fmt.Sprintf("hsl(%d, 60%%, 40%%)", hash.Sum32()%360))
+}
+
+func unique(values []string) []string {
+	var (
+		seen   = map[string]bool{}
+		result = []string{}
+	)
+
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
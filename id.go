@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// messageIDLength is a number of hex digits in message ID.
+const messageIDLength = 12
+
+// messageID returns stable identifier of message: first 12 hex digits of
+// SHA-256 of channel name (file name without rotation suffix, not alias),
+// message time in UTC in RFC 3339 format, direction and message text
+// without formatting sequences, joined by newlines. ID, which is already
+// computed for message, is returned as is, and decrypted message keeps ID
+// of encrypted one.
+func messageID(file HistoryFile, message *Message) string {
+	if message.ID != "" {
+		return message.ID
+	}
+
+	if message.DecryptedID != "" {
+		return message.DecryptedID
+	}
+
+	channel := rotationSuffix.ReplaceAllString(filepath.Base(file.Name), "")
+
+	sum := sha256.Sum256([]byte(strings.Join(
+		[]string{
+			channel,
+			message.Header.Time.UTC().Format(time.RFC3339),
+			string(message.Header.Direction),
+			stripFormatting(message.Text()),
+		},
+		"\n",
+	)))
+
+	return hex.EncodeToString(sum[:])[:messageIDLength]
+}
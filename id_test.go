@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMessageIDDisplayOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 003 <alice>   deploy\tfailed",
+		"> first quoted",
+		"> second quoted",
+		"my answer",
+	)
+
+	search := func(options ...string) string {
+		output, err := runSearch(t, append([]string{
+			"--path", dir, "--since", "100000h", "--with-id",
+		}, append(options, "work")...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, _, _ := strings.Cut(output, " ")
+
+		return id
+	}
+
+	want := search()
+	if len(want) != messageIDLength {
+		t.Fatalf("got ID %q, want %d hex digits", want, messageIDLength)
+	}
+
+	for _, options := range [][]string{
+		{"--fold-quotes"},
+		{"--binary-safe"},
+		{"--normalize-whitespace", "--normalize-display"},
+		{"--matching-lines-only", "--line-regexp"},
+		{"--plain"},
+	} {
+		got := search(options...)
+		if got != want {
+			t.Errorf("ID with %q is %q, want %q", options, got, want)
+		}
+	}
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--json", "--fold-quotes",
+		"work",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message JSONMessage
+
+	err = json.Unmarshal([]byte(strings.SplitN(output, "\n", 2)[0]), &message)
+	if err != nil {
+		t.Fatalf("can't decode %q: %s", output, err)
+	}
+
+	if message.ID != want {
+		t.Errorf("ID in JSON is %q, want %q", message.ID, want)
+	}
+}
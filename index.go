@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp/syntax"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/reconquest/ser-go"
+)
+
+const (
+	// bloomFalsePositives is a target rate of false positives of bloom
+	// filter, which index is built with.
+	bloomFalsePositives = 0.01
+
+	// bloomNgram is a number of characters in substrings, which bloom
+	// filter is built from; literal terms should be at least that long to be
+	// checked against it.
+	bloomNgram = 3
+)
+
+// IndexEntry is a cached summary of single history file. It is valid while
+// modification time and size of file are the same.
+type IndexEntry struct {
+	Path     string    `json:"path"`
+	ModTime  time.Time `json:"mtime"`
+	Size     int64     `json:"size"`
+	Messages int       `json:"messages"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	Bloom    *Bloom    `json:"bloom"`
+}
+
+// Bloom is a bloom filter over lowercase 3-character substrings of
+// messages, as they are matched by filter.
+type Bloom struct {
+	Hashes int    `json:"hashes"`
+	Bits   []byte `json:"bits"`
+}
+
+// Index stores entries of history files in cache directory, one file per
+// history file.
+type Index struct {
+	Dir string
+}
+
+// BloomPrefilter skips history files, which definitely don't contain some
+// of literal filter terms, judging by bloom filters from index. Bloom filter
+// can report term, which is not in file, but never misses term, which is.
+type BloomPrefilter struct {
+	Index Index
+	Terms []string
+}
+
+// newBloomPrefilter returns prefilter for literal terms of filter, which are
+// long enough to check them against bloom filter, or nil, if there are no
+// such terms.
+func newBloomPrefilter(index Index, terms []string) *BloomPrefilter {
+	prefilter := &BloomPrefilter{
+		Index: index,
+	}
+
+	for _, term := range terms {
+		literal, ok := extractLiteral(term)
+		if ok && utf8.RuneCountInString(literal) >= bloomNgram {
+			prefilter.Terms = append(prefilter.Terms, strings.ToLower(literal))
+		}
+	}
+
+	if len(prefilter.Terms) == 0 {
+		return nil
+	}
+
+	return prefilter
+}
+
+// extractLiteral returns literal string, which regexp matches, if regexp is
+// plain literal, like "deploy" or "foo\.bar".
+func extractLiteral(term string) (string, bool) {
+	expression, err := syntax.Parse(term, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	expression = expression.Simplify()
+	if expression.Op != syntax.OpLiteral {
+		return "", false
+	}
+
+	return string(expression.Rune), true
+}
+
+// MayContain reports whether history file may contain all terms. File is
+// indexed, if index entry for it is missing or stale. True is returned, if
+// file can't be indexed, like archive entry or stdin.
+func (prefilter *BloomPrefilter) MayContain(file HistoryFile) bool {
+	if file.Format != "" || file.Archive != "" {
+		return true
+	}
+
+	entry, err := prefilter.Index.Get(file.Name)
+	if err != nil {
+		slog.Debug(
+			"can't use index for history file",
+			"file", file.Name,
+			"error", err,
+		)
+
+		return true
+	}
+
+	for _, term := range prefilter.Terms {
+		if !entry.Bloom.Test(term) {
+			stats.SkippedIndex++
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get returns valid index entry for history file, building and storing it,
+// if entry is missing or stale.
+func (index Index) Get(path string) (*IndexEntry, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, ser.Errorf(err, "can't stat history file %q", path)
+	}
+
+	entry, err := index.Read(path)
+	if err == nil && entry.IsFresh(stat) {
+		return entry, nil
+	}
+
+	return index.Build(path)
+}
+
+// Build indexes history file and stores its entry in index.
+func (index Index) Build(path string) (*IndexEntry, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(err, "can't open history file %q", path)
+	}
+
+	defer handle.Close()
+
+	stat, err := handle.Stat()
+	if err != nil {
+		return nil, ser.Errorf(err, "can't stat history file %q", path)
+	}
+
+	var (
+		entry = &IndexEntry{
+			Path:    path,
+			ModTime: stat.ModTime(),
+			Size:    stat.Size(),
+		}
+		ngrams = map[string]bool{}
+	)
+
+	err = readMessages(path, handle, func(message *Message) error {
+		if entry.Messages == 0 {
+			entry.First = message.Header.Time
+		}
+
+		entry.Messages++
+		entry.Last = message.Header.Time
+
+		text := strings.ToLower(stripFormatting(formatMessage(message)))
+		for _, ngram := range splitNgrams(text) {
+			ngrams[ngram] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Bloom = newBloom(len(ngrams))
+	for ngram := range ngrams {
+		entry.Bloom.Add(ngram)
+	}
+
+	return entry, index.Write(entry)
+}
+
+// Read reads index entry for history file without checking whether it is
+// stale.
+func (index Index) Read(path string) (*IndexEntry, error) {
+	data, err := os.ReadFile(index.path(path))
+	if err != nil {
+		return nil, ser.Errorf(err, "can't read index entry of %q", path)
+	}
+
+	entry := &IndexEntry{}
+
+	err = json.Unmarshal(data, entry)
+	if err != nil {
+		return nil, ser.Errorf(err, "can't parse index entry of %q", path)
+	}
+
+	if entry.Bloom == nil || entry.Bloom.Hashes == 0 ||
+		len(entry.Bloom.Bits) == 0 {
+		return nil, ser.Errorf(
+			nil,
+			"index entry of %q has no bloom filter",
+			path,
+		)
+	}
+
+	return entry, nil
+}
+
+// Write stores index entry, replacing previous one atomically.
+func (index Index) Write(entry *IndexEntry) error {
+	err := os.MkdirAll(index.Dir, 0700)
+	if err != nil {
+		return ser.Errorf(err, "can't create index directory %q", index.Dir)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := index.path(entry.Path)
+
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		return ser.Errorf(err, "can't write index entry %q", path)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// path returns path of index entry for history file: hash of its absolute
+// path in index directory.
+func (index Index) path(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err == nil {
+		path = absolute
+	}
+
+	sum := sha256.Sum256([]byte(path))
+
+	return filepath.Join(index.Dir, hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+// IsFresh reports whether entry describes file with specified stat.
+func (entry *IndexEntry) IsFresh(stat os.FileInfo) bool {
+	return entry.ModTime.Equal(stat.ModTime()) && entry.Size == stat.Size()
+}
+
+// splitNgrams returns all substrings of text of bloomNgram characters.
+func splitNgrams(text string) []string {
+	var (
+		ngrams = []string{}
+		starts = []int{}
+	)
+
+	for position := range text {
+		starts = append(starts, position)
+	}
+
+	starts = append(starts, len(text))
+
+	for i := 0; i+bloomNgram < len(starts); i++ {
+		ngrams = append(ngrams, text[starts[i]:starts[i+bloomNgram]])
+	}
+
+	return ngrams
+}
+
+// newBloom creates bloom filter, sized for specified number of items with
+// bloomFalsePositives rate.
+func newBloom(items int) *Bloom {
+	items = max(items, 1)
+
+	var (
+		bits = -float64(items) * math.Log(bloomFalsePositives) /
+			(math.Ln2 * math.Ln2)
+		hashes = int(math.Round(bits / float64(items) * math.Ln2))
+	)
+
+	return &Bloom{
+		Hashes: max(hashes, 1),
+		Bits:   make([]byte, (int(bits)+7)/8),
+	}
+}
+
+// Add adds substring of bloomNgram characters to bloom filter.
+func (bloom *Bloom) Add(item string) {
+	for _, bit := range bloom.positions(item) {
+		bloom.Bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test reports whether item may be in bloom filter. Every substring of item
+// of bloomNgram characters is tested, so item of any length can be tested.
+func (bloom *Bloom) Test(item string) bool {
+	for _, ngram := range splitNgrams(item) {
+		for _, bit := range bloom.positions(ngram) {
+			if bloom.Bits[bit/8]&(1<<(bit%8)) == 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// positions returns bits of item, computed by double hashing.
+func (bloom *Bloom) positions(item string) []uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(item))
+
+	var (
+		sum    = hash.Sum64()
+		first  = sum & math.MaxUint32
+		second = sum >> 32
+		size   = uint64(len(bloom.Bits)) * 8
+	)
+
+	// Second hash is made odd, so it doesn't degrade to zero.
+	second |= 1
+
+	positions := make([]uint64, bloom.Hashes)
+	for i := range positions {
+		positions[i] = (first + uint64(i)*second) % size
+	}
+
+	return positions
+}
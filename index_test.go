@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBloomPrefilterArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	content := "MR 20240102T10:00:00Z 000 <alice> deploy failed\n"
+
+	path := filepath.Join(dir, "history.tar.gz")
+
+	writeArchive(t, path, "work", content)
+
+	// Unrelated file with the same name as archive entry shouldn't be
+	// indexed in place of it.
+	cwd := t.TempDir()
+	writeHistory(t, cwd, "work", "MR 20240102T10:00:00Z 000 <bob> hello")
+	t.Chdir(cwd)
+
+	index := t.TempDir()
+
+	output, err := runSearch(t,
+		"--archive", path, "--since", "100000h", "--plain",
+		"--bloom-prefilter", "--index-dir", index, "work", "deploy",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "deploy failed") {
+		t.Errorf("archive entry is skipped, got:\n%s", output)
+	}
+
+	entries, err := os.ReadDir(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("archive entry is indexed: %d index entries", len(entries))
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// Statuses of index entries in --dump-index output.
+const (
+	IndexFresh   = "fresh"
+	IndexStale   = "stale"
+	IndexMissing = "missing"
+	IndexInvalid = "invalid"
+)
+
+// IndexStatus describes index entry of single history file.
+type IndexStatus struct {
+	Path    string `json:"path"`
+	Channel string `json:"channel"`
+
+	// Status is fresh, if entry is valid, stale, if history file was changed
+	// after entry was built, missing, if file is not indexed yet, or
+	// invalid, if entry can't be read.
+	Status   string    `json:"status"`
+	Messages int       `json:"messages"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	ModTime  time.Time `json:"mtime"`
+	Size     int64     `json:"size"`
+
+	// BloomSize is a size of bloom filter of entry in bytes.
+	BloomSize int `json:"bloom_size"`
+}
+
+// indexedHistory returns history files, specified by command line arguments,
+// which can be indexed: files from archive and stdin can't be.
+func indexedHistory(
+	args map[string]interface{},
+	mode string,
+) ([]HistoryFile, error) {
+	if _, ok := args["--archive"].(string); ok {
+		return nil, fmt.Errorf("%s can't be used with --archive", mode)
+	}
+
+	if isStdin(args) {
+		return nil, fmt.Errorf("%s can't be used with history from stdin", mode)
+	}
+
+	selector, err := newChannelSelector(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return listHistory(args, selector)
+}
+
+// dumpIndex prints index entries of history files. Entries are not built or
+// updated, so stale and missing ones are reported as is.
+func dumpIndex(args map[string]interface{}) error {
+	files, err := indexedHistory(args, "--dump-index")
+	if err != nil {
+		return err
+	}
+
+	index := Index{Dir: args["--index-dir"].(string)}
+
+	statuses := []IndexStatus{}
+	for _, file := range files {
+		stat, err := os.Stat(file.Name)
+		if err != nil {
+			err = fileErrors.Collect(
+				ser.Errorf(err, "can't stat history file %q", file.Name),
+			)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		statuses = append(statuses, readIndexStatus(index, file, stat))
+	}
+
+	err = printIndexStatuses(statuses, args["--json"].(bool))
+	if err != nil {
+		return err
+	}
+
+	return fileErrors.Err()
+}
+
+func readIndexStatus(
+	index Index,
+	file HistoryFile,
+	stat os.FileInfo,
+) IndexStatus {
+	status := IndexStatus{
+		Path:    file.Name,
+		Channel: file.Channel(),
+	}
+
+	_, err := os.Stat(index.path(file.Name))
+	if os.IsNotExist(err) {
+		status.Status = IndexMissing
+
+		return status
+	}
+
+	entry, err := index.Read(file.Name)
+	switch {
+	case err != nil:
+		status.Status = IndexInvalid
+
+		return status
+
+	case entry.IsFresh(stat):
+		status.Status = IndexFresh
+
+	default:
+		status.Status = IndexStale
+	}
+
+	status.Messages = entry.Messages
+	status.First = entry.First
+	status.Last = entry.Last
+	status.ModTime = entry.ModTime
+	status.Size = entry.Size
+	status.BloomSize = len(entry.Bloom.Bits)
+
+	return status
+}
+
+func printIndexStatuses(statuses []IndexStatus, asJSON bool) error {
+	if asJSON {
+		return printJSON(statuses)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(
+		writer,
+		"file\tchannel\tstatus\tmessages\tfirst\tlast\tmtime\tbloom",
+	)
+
+	for _, status := range statuses {
+		if status.Status == IndexMissing || status.Status == IndexInvalid {
+			fmt.Fprintf(
+				writer,
+				"%s\t%s\t%s\t-\t-\t-\t-\t-\n",
+				status.Path,
+				status.Channel,
+				status.Status,
+			)
+
+			continue
+		}
+
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%d\t%s\t%s\t%s\t%d\n",
+			status.Path,
+			status.Channel,
+			status.Status,
+			status.Messages,
+			status.First.Format(time.ANSIC),
+			status.Last.Format(time.ANSIC),
+			status.ModTime.Format(time.ANSIC),
+			status.BloomSize,
+		)
+	}
+
+	return writer.Flush()
+}
+
+// rebuildIndex builds index entries of history files anew, even if they are
+// fresh.
+func rebuildIndex(args map[string]interface{}) error {
+	files, err := indexedHistory(args, "--rebuild-index")
+	if err != nil {
+		return err
+	}
+
+	index := Index{Dir: args["--index-dir"].(string)}
+
+	for _, file := range files {
+		_, err := index.Build(file.Name)
+		if err != nil {
+			err = fileErrors.Collect(err)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return fileErrors.Err()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// InfoMerger collapses runs of consecutive info messages of the same
+// channel, like presence changes during join and leave storms, into single
+// summary line.
+type InfoMerger struct {
+	// Window is a maximum time between adjacent info messages of run.
+	Window time.Duration
+
+	// Threshold is a minimum number of messages in run to collapse it;
+	// shorter runs are printed as is.
+	Threshold int
+
+	// Expand makes merger to print collapsed messages after summary line.
+	Expand bool
+}
+
+// print buffers info messages while they continue current run and prints
+// previous run, along with message, otherwise.
+func (searcher *Searcher) print(file HistoryFile, message *Message) {
+	if searcher.MergeAdjacentInfo == nil {
+		searcher.printMessage(file, message)
+
+		return
+	}
+
+	if message.Header.Direction != DirectionInfo {
+		searcher.flushInfo()
+		searcher.printMessage(file, message)
+
+		return
+	}
+
+	if !searcher.continuesInfo(file, message) {
+		searcher.flushInfo()
+	}
+
+	searcher.infoRun = append(
+		searcher.infoRun,
+		scoredMessage{file: file, message: message},
+	)
+}
+
+// continuesInfo reports whether info message continues current run: it's
+// in the same channel within Window after the last message of run.
+func (searcher *Searcher) continuesInfo(
+	file HistoryFile,
+	message *Message,
+) bool {
+	if len(searcher.infoRun) == 0 || message.ContextBreak {
+		return false
+	}
+
+	last := searcher.infoRun[len(searcher.infoRun)-1]
+	if file.Label != last.file.Label || file.Channel() != last.file.Channel() {
+		return false
+	}
+
+	gap := message.Header.Time.Sub(last.message.Header.Time)
+
+	return gap >= 0 && gap <= searcher.MergeAdjacentInfo.Window
+}
+
+// flushInfo prints buffered run of info messages: as summary line, if run
+// is long enough, or message by message otherwise.
+func (searcher *Searcher) flushInfo() {
+	run := searcher.infoRun
+	searcher.infoRun = nil
+
+	if len(run) == 0 {
+		return
+	}
+
+	merger := searcher.MergeAdjacentInfo
+
+	if len(run) < merger.Threshold {
+		for _, info := range run {
+			searcher.printMessage(info.file, info.message)
+		}
+
+		return
+	}
+
+	first := run[0]
+
+	header := *first.message.Header
+	header.Message = fmt.Sprintf("%d presence changes", len(run))
+
+	summary := *first.message
+	summary.Header = &header
+	summary.Body = nil
+
+	searcher.printMessage(first.file, &summary)
+
+	if !merger.Expand {
+		return
+	}
+
+	for _, info := range run {
+		searcher.printRecord(
+			color.New(color.Faint).Sprint("    " + formatMessage(info.message)),
+		)
+	}
+}
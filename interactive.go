@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// cachedFile is a history file with all its messages parsed.
+type cachedFile struct {
+	file     HistoryFile
+	messages []*Message
+}
+
+// interactive parses history of specified channel once and then repeatedly
+// reads filter from stdin, line by line, printing messages matching it.
+func interactive(args map[string]interface{}) error {
+	cache, err := readCache(args)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "filter> ")
+
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		err := searchCache(args, cache, strings.Fields(scanner.Text()))
+		if err != nil {
+			slog.Error(err.Error())
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readCache parses all messages of history files, specified by command line
+// arguments.
+func readCache(args map[string]interface{}) ([]cachedFile, error) {
+	cache := []cachedFile{}
+
+	err := walkHistory(
+		args,
+		func(file HistoryFile, reader io.Reader) error {
+			cached := cachedFile{file: file}
+
+			err := readMessages(
+				file.Location(),
+				reader,
+				func(message *Message) error {
+					cached.messages = append(cached.messages, message)
+					return nil
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			cache = append(cache, cached)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+func searchCache(
+	args map[string]interface{},
+	cache []cachedFile,
+	terms []string,
+) error {
+	searcher, err := newSearcher(args, terms)
+	if err != nil {
+		return err
+	}
+
+	for _, cached := range cache {
+		err := searcher.SearchMessages(cached.file, cached.messages)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = searcher.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "(%d matches)\n", searcher.emitted)
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSearchCacheKeepsMessages(t *testing.T) {
+	messages, err := readTestMessages(t,
+		"MR 20240102T10:00:00Z 000 <alice> deploy failed\n"+
+			"MR 20240102T10:00:30Z 000 <bob> hello\n",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := []cachedFile{{file: HistoryFile{Name: "work"}, messages: messages}}
+
+	buffer := &bytes.Buffer{}
+
+	stdout = buffer
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	args := parseTestArgs(t,
+		"--interactive", "--since", "100000h", "--explain-match",
+		"--after-time", "1m", "work",
+	)
+
+	err = searchCache(args, cache, []string{"deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range messages {
+		if message.Explanation != "" || message.Context {
+			t.Errorf(
+				"cached message %q is annotated by search",
+				message.Header.Message,
+			)
+		}
+	}
+
+	buffer.Reset()
+
+	args = parseTestArgs(t, "--interactive", "--since", "100000h", "work")
+
+	err = searchCache(args, cache, []string{"hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buffer.String(), "matched") {
+		t.Errorf("explanation of previous filter is printed:\n%s", buffer)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/reconquest/ser-go"
+)
+
+// JIDMap maps sender JIDs to human-readable names.
+type JIDMap map[string]string
+
+// readJIDMap reads JID map from file with lines in form of <jid>=<name>,
+// skipping blank lines and comments, starting with #.
+func readJIDMap(path string) (JIDMap, error) {
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open JID map %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	jids := JIDMap{}
+
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		jid, name, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, ser.Errorf(
+				nil,
+				"can't parse JID map %q line %q: should be <jid>=<name>",
+				path,
+				line,
+			)
+		}
+
+		jids[strings.ToLower(strings.TrimSpace(jid))] = strings.TrimSpace(name)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't read JID map %q",
+			path,
+		)
+	}
+
+	return jids, nil
+}
+
+// Name returns name for specified sender: name of full JID, if it's mapped,
+// otherwise name of bare JID without resource, otherwise sender as is.
+func (jids JIDMap) Name(sender string) string {
+	if name, ok := jids[strings.ToLower(sender)]; ok {
+		return name
+	}
+
+	bare, _, _ := strings.Cut(sender, "/")
+	if name, ok := jids[strings.ToLower(bare)]; ok {
+		return name
+	}
+
+	return sender
+}
+
+// Resolve returns copy of message with sender nick replaced by its name.
+func (jids JIDMap) Resolve(message *Message) *Message {
+	nick := extractNick(message)
+	if nick == "" {
+		return message
+	}
+
+	name := jids.Name(nick)
+	if name == nick {
+		return message
+	}
+
+	header := *message.Header
+	header.Message = "<" + name + ">" +
+		strings.TrimPrefix(header.Message, "<"+nick+">")
+
+	resolved := *message
+	resolved.Header = &header
+
+	return &resolved
+}
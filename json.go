@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+)
+
+// JSONMessage is a representation of matching message in JSON output.
+type JSONMessage struct {
+	ID        string    `json:"id"`
+	Channel   string    `json:"channel"`
+	File      string    `json:"file"`
+	Label     string    `json:"label,omitempty"`
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"direction"`
+	Nick      string    `json:"nick,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Code      string    `json:"code,omitempty"`
+	Mentioned []string  `json:"mentioned,omitempty"`
+	URLs      []string  `json:"urls,omitempty"`
+	Decrypted bool      `json:"decrypted,omitempty"`
+	Text      string    `json:"text"`
+	Offset    int64     `json:"offset"`
+	Length    int64     `json:"length"`
+}
+
+func newJSONMessage(file HistoryFile, message *Message) JSONMessage {
+	presence, _ := parsePresence(message)
+
+	return JSONMessage{
+		ID:        messageID(file, message),
+		Channel:   file.Channel(),
+		File:      file.Name,
+		Label:     file.Label,
+		Time:      message.Header.Time,
+		Direction: message.Header.Direction,
+		Nick:      extractNick(message),
+		Status:    presence.Status,
+		Code:      message.Code,
+		Mentioned: message.Mentioned,
+		URLs:      message.URLs,
+		Decrypted: message.DecryptedID != "",
+		Text:      message.Text(),
+		Offset:    message.Offset,
+		Length:    message.Size,
+	}
+}
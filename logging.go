@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging configures default logger, which all diagnostics are printed
+// with, according to --log-level and --log-format.
+func setupLogging(args map[string]interface{}) error {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(args["--log-level"].(string)))
+	if err != nil {
+		return fmt.Errorf(
+			"can't parse log level %q: %s",
+			args["--log-level"].(string), err,
+		)
+	}
+
+	options := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	var handler slog.Handler
+
+	switch format := args["--log-format"].(string); strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return nil
+}
+
+// fatal logs error and exits with non-zero code.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
@@ -1,18 +1,14 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/fatih/color"
-
 	"github.com/docopt/docopt-go"
 	"github.com/reconquest/ser-go"
 )
@@ -25,17 +21,539 @@ Tool for searching mcabber history using history file parsing and filtering.
 
 Usage:
   mcabber-history -h | --help
-  mcabber-history [options] -S <channel> [<filter>...]
+  mcabber-history [options] [(--path <path>)...] [(--alias <alias>)...]
+                  (-S | --dump-parsed | --interactive | --browse |
+                  --dump-index | --rebuild-index)
+                  (--channels-file <file> [<channel>] |
+                  --channels-from-stdin | --all-channels | <channel>)
+                  [<filter>...]
+  mcabber-history [options] --at <location>
+  mcabber-history --json-schema
 
 Options:
   -h --help                 Show this help.
   -S                        Search specified channel by specified filter.
-  --path <path>             Path to history files directory.
-                             [default: $HOME/.mcabber/history]
+                             Several channels can be specified, delimited by
+                             comma. Channel "-" means history, piped to
+                             stdin.
+  --stdin-format <format>   Format of history, piped to stdin: mcabber or
+                             generic, which is one message per line in form
+                             of "2006-01-02 15:04:05 nick: text"; RFC 3339
+                             time can be used too. [default: mcabber]
+  -l --files-with-matches   Print only names of history files with matching
+                             messages.
+  -Z --output-null          Terminate every printed message or file name with
+                             NUL byte instead of newline and don't print
+                             separators, like for xargs -0. Most useful
+                             with --files-with-matches and --plain.
+  --dump-parsed             Print how every header line in specified channel
+                             history is parsed and exit. Useful for debugging
+                             timestamp and format issues.
+  --at <location>           Print single message at specified location,
+                             which is printed by --show-offsets in form of
+                             <file>:<offset>, without scanning whole file.
+  --interactive             Parse specified channel history once and then
+                             read filters from stdin line by line, printing
+                             matching messages for every filter.
+  --browse                  Parse specified channels history once and browse
+                             it in full-screen mode: matches are updated
+                             while filter is typed, Tab switches between all
+                             and single channel, arrows and Page Up/Down
+                             scroll, Enter shows context of selected match.
+  --path <path>             Path to history files directory, optionally
+                             prefixed with label in form <label>=<path>.
+                             Can be repeated to search several directories;
+                             matches are prefixed with label then, which
+                             defaults to directory base name.
+                             Defaults to $HOME/.mcabber/history.
+  --alias <alias>           Define short name for channel in form of
+                             <alias>=<channel>, like team=team@conf.host.
+                             Alias can be used instead of channel name in
+                             arguments and is printed instead of it. Can be
+                             repeated.
+  --channels-file <file>    Read channels to search from file, one channel
+                             per line. Blank lines and lines, starting with
+                             # are skipped. Channels from channel argument,
+                             if it's specified, are searched too, so first
+                             argument after options is always channel.
+  --channels-from-stdin     Read channels to search from stdin, same way as
+                             from --channels-file. Unlike --channels-file,
+                             can't be combined with channel argument, so
+                             channel "-" still means history from stdin.
+  --all-channels            Search every history file in --path, except ones
+                             ignored by --ignore-channels. Passing "*" as
+                             channel has the same effect. Whole history is
+                             read in that case, so search time is
+                             proportional to its total size; --since doesn't
+                             reduce amount of data read, only printed.
+  --channels-active-since <time>
+                            Search only channels, which last message was
+                             written within specified time, like 24h. Only
+                             tail of the current history file of channel is
+                             read to check it. Combine with --overview to
+                             discover recently active channels.
+  --max-file-size <size>    Skip history files larger than specified size in
+                             bytes; K, M and G suffixes can be used, like
+                             10M. All files are searched by default.
+  --bloom-prefilter         Skip history files, which don't contain literal
+                             filter terms, like "deploy", judging by index
+                             with bloom filter of every file. Index entry is
+                             built on first search and rebuilt, when file is
+                             modified. Bloom filter can't miss term, which is
+                             in file, but can report term, which is not: such
+                             file is just searched as usual. Only terms of
+                             three and more characters without regexp syntax
+                             are checked.
+  --dump-index              Print index entries of specified channels history
+                             files: status, number of messages, times of the
+                             first and the last of them, modification time of
+                             file and size of bloom filter. Status is stale,
+                             if file was modified after entry was built.
+  --rebuild-index           Build index entries of specified channels history
+                             files anew.
+  --index-dir <dir>         Directory to store index of history files in.
+                             Defaults to $HOME/.cache/mcabber-history/index.
+  --no-expand-env           Don't expand environment variables, like $HOME,
+                             in default values of options, including default
+                             of --path. Values, passed in command line, are
+                             never expanded.
+  --jobs <n>                Number of history files, which are parsed in
+                             parallel. Output doesn't depend on it: messages
+                             are searched and printed in order of files, so
+                             repeated runs print the same. Merged output of
+                             several files is always read in single thread.
+                             [default: 1]
+  --mmap                    Map history files into memory instead of reading
+                             them with read syscalls. Helps with large files
+                             on local disks, which are already in page cache;
+                             gives nothing for small files. Files are read as
+                             usual on platforms without mmap support.
+  --log-level <level>       Print diagnostics of specified level and above:
+                             debug, info, warn or error. [default: info]
+  --log-format <format>     Format of diagnostics, printed to stderr: text or
+                             json. [default: text]
+  --stats                   Print number of searched and skipped files and
+                             number of matching messages to stderr after
+                             search.
   --ignore-channels <chan>  Ignore channels, delimited by comma, matched by
                              prefix.
-  --since <time>            Print only messages since specified time.
-                             [default: 24h]
+  --ignore-case-channels    Match channel and ignored channels names
+                             case-insensitively, as plain prefixes.
+  --strict                  Fail on incomplete message at the end of history
+                             file instead of skipping it. Such message is
+                             usually being written by mcabber at the moment.
+                             Implies --fail-fast.
+  --fail-fast               Abort on the first error in history file, like
+                             file, which can't be opened, or malformed line.
+  --collect-errors          Report errors in history files, skipping rest of
+                             such file, and search other files, exiting with
+                             non-zero code after all of them are searched.
+                             It's default behavior.
+  --skip-errors             Skip malformed lines in history file until the
+                             next line, which parses as message header,
+                             instead of failing.
+  --robust-resync           Same as --skip-errors, but resync only on header
+                             with plausible length and time, close to time
+                             of previous message, so body lines of broken
+                             message, which look like headers, are skipped.
+  --follow                  After search is finished, wait for new messages
+                             in history files and search them too, until
+                             interrupted.
+  --rate-limit <rate>       Print at most specified number of messages per
+                             time interval in --follow mode, like 10/1m;
+                             number of dropped messages is printed instead.
+                             Doesn't limit messages, found before following.
+  --since <time>            Print only messages since specified time, like
+                             90m, 24h, 3d, 2w, 1mo or 1y6mo; months and
+                             years are counted by calendar. Defaults to 24h.
+  --as-of <time>            Count --since and other relative times from
+                             specified moment instead of current time, like
+                             "2024-05-01 14:30", and print only messages,
+                             written before it.
+  --at-time <timestamp>     Print only messages, written at specified time
+                             with tolerance of one second, like all messages
+                             of the same second. Time is specified as in
+                             history files, like 20240102T15:04:05Z, or as
+                             for --as-of. Fails, if there are no such
+                             messages. Overrides --since.
+  --since-last-read         Print only messages since the last read message
+                             of every channel, as recorded in --read-markers
+                             file. Channels without marker are searched
+                             since --since.
+  --read-markers <file>     File with lines in form of <channel> <time>,
+                             where time is in format of history headers, like
+                             20240102T15:04:05Z. Mcabber doesn't keep read
+                             position on disk itself, so file is expected to
+                             be written by hook or script. Defaults to
+                             $HOME/.mcabber/read_markers.
+  --since-file <file>       Print only messages since modification time of
+                             specified file. Takes precedence over --since,
+                             which is used if file doesn't exist.
+  --touch-since-file        Set modification time of --since-file to the
+                             time search was started at after successful
+                             search, creating file if needed.
+  --after-silence <time>    Print only messages, written at least specified
+                             time after previous message in the same file,
+                             like ones, starting new discussion. The first
+                             message of every file is considered to be such.
+  --weekday <days>          Print only messages, written on specified
+                             weekdays, delimited by comma. Weekday is
+                             specified by name, like Mon or Monday, or by
+                             number from 0 to 7, where 0 and 7 is Sunday.
+  --archive <tarball>       Search history files stored in specified .tar.gz
+                             archive instead of --path directory.
+  --before-time <time>      Print also messages from the same file, which
+                             were written within specified duration before
+                             matching message.
+  --after-time <time>       Same as --before-time, but for messages written
+                             after matching message.
+  --context-separator <s>   Line, which is printed between context blocks,
+                             printed by --before-time and --after-time, if
+                             they are not adjacent. Context messages are
+                             printed dimmed. [default: --]
+  --no-context-separator    Print usual separator between context blocks.
+  --merge-info-into-context  Print info messages, like joins and leaves,
+                             around matching messages as their context, even
+                             though info messages don't match themselves.
+  --info-window <window>    Time window around matching message, which info
+                             messages are merged from. [default: 5m]
+  --fold-quotes             Collapse consecutive quoted lines of message body
+                             into single placeholder line when printing.
+  --quote-prefix <prefix>   Prefix, which quoted lines start with.
+                             [default: >]
+  --near <n>                Match messages, which contain all filter terms
+                             in any order with at most specified number of
+                             other words between them, instead of matching
+                             terms as sequence. Every term is a regexp, which
+                             is matched against single words.
+  --exact-match             Match only messages, which text is entirely
+                             matched by filter, like "+1". Sender nick and
+                             leading and trailing whitespace of message are
+                             not taken into account.
+  --fixed-strings           Treat filter terms as literal strings instead of
+                             regexps.
+  --phrase                  Match filter terms as contiguous phrase,
+                             separated by single spaces, instead of sequence
+                             of terms with anything between them.
+  --lines-min <n>           Print only messages with at least specified
+                             number of lines, including first one.
+  --lines-max <n>           Print only messages with at most specified number
+                             of lines, including first one.
+  --line-regexp             Match filter against every message line
+                             separately instead of whole message; matching
+                             lines are highlighted.
+  --matching-lines-only     Same as --line-regexp, but print only first line
+                             of message and matching lines.
+  --corrections-only        Print only messages, which look like corrections
+                             of previous message: sent by the same sender
+                             shortly after it with nearly identical text.
+                             Such messages are marked in normal output too.
+  --replies-only            Print only messages, which look like replies:
+                             history doesn't keep reply markers of clients,
+                             so reply is a message, starting with line quoted
+                             by --quote-prefix, which text is found in one of
+                             100 previous messages of the same history file.
+  --include-info            Match also info messages, like joins and leaves;
+                             presence changes are colored by status.
+  --status <status>         Print only info messages, which describe presence
+                             change to specified status: online, free, away,
+                             xa, dnd, invisible or offline. History doesn't
+                             store presence separately, so it is guessed by
+                             text: "<nick> has joined" is online, "<nick> has
+                             left" is offline and "<nick> is now <status>"
+                             is specified status. Implies --include-info.
+  --code                    Print only messages, which look like pasted code:
+                             messages with fences of three backticks or
+                             multi-line messages with indented lines.
+                             Detection reason is reported in "code" field of
+                             JSON output.
+  --code-min-lines <n>      Minimum number of lines in message to detect it
+                             as indented code [default: 3].
+  --no-code-fences          Don't detect code by backtick fences.
+  --unanswered              Experimental. Print only sent questions, which
+                             are not followed by any received message in the
+                             same channel within reply window. All messages
+                             of history file are kept in memory until it is
+                             searched.
+  --reply-window <window>   Time after question, which reply is expected
+                             within. [default: 1h]
+  --question-regexp <re>    Regexp, which matches sent messages, which are
+                             questions. [default: \?\s*$]
+  --context-requires <re>   Print only matching messages, which have message
+                             matching specified regexp among surrounding
+                             messages of the same file within context window.
+                             It only filters matches: context, printed with
+                             options --before-time and --after-time, is
+                             printed around messages, which passed it, and
+                             context messages are not required to match
+                             anything.
+  --context-window <window>  Number of messages, like 3, or time, like 10m,
+                             before and after matching message, which are
+                             looked up by --context-requires. [default: 5m]
+  --highlight-nick <nick>   Nick of user, which received messages, mentioning
+                             it, like "nick: hi" or "@nick", are marked in
+                             output. Defaults to MCABBER_NICK environment
+                             variable.
+  --ignore-own              Skip sent messages and also received messages in
+                             multi-user chats from nick, which is specified
+                             by --highlight-nick.
+  --mentions-only           Print only received messages, which mention nick,
+                             specified by --highlight-nick.
+  --mentions <nicks>        Print only messages, which mention all of nicks,
+                             delimited by comma, like "nick: hi", "@nick" or
+                             "ask nick", no matter who sent the message.
+  --mentions-any            Print messages, which mention any of nicks,
+                             specified by --mentions, instead of all of them.
+  --url-domain <domain>     Print only messages with URLs, which host is
+                             specified domain or its subdomain, like
+                             github.com. Punycode hosts match their Unicode
+                             names. Matched URLs are listed in --json output.
+  --strip-formatting        Remove ANSI escape sequences and IRC formatting
+                             codes (bold, color, italic, underline, reverse,
+                             reset) from messages before matching them.
+  --explain-match           Print line under every matching message, listing
+                             filter terms, text they matched and offsets of
+                             it in message, as printed without colors. Not
+                             printed in JSON and other structured output.
+  --binary-safe             Replace control characters, except tab, and
+                             bytes, which are not valid UTF-8, with escapes
+                             of their bytes in form of \xNN, like \x00 or
+                             \x1b, before matching and printing messages.
+                             Escaped form can be matched by filter, like
+                             '\\x07'. Backslashes themselves are not escaped.
+                             Applied after --strip-formatting.
+  --normalize-whitespace    Collapse runs of spaces and tabs in every line of
+                             message into single space and trim lines before
+                             matching, so phrases match despite spacing.
+  --normalize-display       Also print messages with normalized whitespace.
+  --raw                     Print messages as is, even if --strip-formatting
+                             or --binary-safe is used for matching.
+  --plain                   Print messages without direction arrows and
+                             colors: only time, sender and text.
+  --anonymize               Replace identities in output with pseudonyms,
+                             like User1 or Phone1, which are the same for the
+                             same identity during search, so logs can be
+                             shared. Sender nicks are replaced in texts of
+                             messages after they are first seen as senders.
+  --anonymize-only <kinds>  Comma-separated kinds of identities, which are
+                             replaced by --anonymize: nick for sender nicks,
+                             address for JIDs and emails, including channel
+                             names, and phone for phone numbers.
+                             [default: nick,address,phone]
+  --anonymize-map           Print pseudonyms along with identities they
+                             replace to stderr after search.
+  --highlight               Highlight occurrences of filter terms in printed
+                             messages, unless colors are disabled.
+  --max-highlights <n>      Highlight at most specified number of occurrences
+                             of filter terms in every printed message, noting
+                             number of the rest. Implies --highlight.
+  --iso-timestamps          Print time of messages in RFC 3339 format with
+                             zone offset of local time zone, which can be set
+                             by TZ environment variable, like
+                             2024-01-02T15:04:05+03:00.
+  --no-color                Don't use colors in output. Colors are disabled
+                             by default, if output is not terminal.
+  --force-color             Use colors in output even if it is not terminal.
+                             Takes precedence over --no-color and --plain.
+  --exec <cmd>              Run shell command for every matching message
+                             instead of printing it. Message text is passed
+                             on stdin, while channel name, time and direction
+                             are passed in MCH_CHANNEL, MCH_TIME and MCH_DIR
+                             environment variables.
+  --exec-batch <cmd>        Run shell command once, passing all matching
+                             messages on stdin.
+  --exec-abort              Abort search if command fails instead of logging
+                             error and continuing.
+  --gaps <n>                Print report of specified number of largest
+                             silence gaps between matching messages and of
+                             densest bursts of them instead of messages.
+  --burst-window <time>     Duration of burst for --gaps report.
+                             [default: 5m]
+  --diff                    Print report of messages, written since --since,
+                             compared to baseline window: messages, written
+                             between --baseline-since and --since. Report
+                             contains count of messages in both windows, new
+                             messages themselves and words, which became more
+                             frequent in new window than in baseline one.
+  --baseline-since <time>   Start of baseline window for --diff; should be
+                             longer than --since.
+  --word-freq <n>           Print report of specified number of most frequent
+                             words in matching messages instead of messages.
+  --stopwords <file>        Skip words from specified file, one per line,
+                             in --word-freq report instead of default list
+                             of common english words.
+  --urls                    Print URLs from matching messages instead of
+                             messages: time and channel of the first message
+                             with URL, number of times it was shared and URL
+                             itself.
+  --count-total             Print only total number of matching messages in
+                             all history files. Exits with non-zero code, if
+                             there are no matching messages.
+  --bucket <duration>       Print number of matching messages in every time
+                             interval of specified size, like 1h, since
+                             time, specified by --since, till now.
+                             Intervals are aligned to their size in UTC;
+                             empty ones are printed too.
+                             With --json, prints bucket_start and count
+                             objects for time-series databases.
+  --list-nicks              Print distinct nicks of senders of matching
+                             messages with number of messages and times of
+                             the first and the last of them. Private chat
+                             messages are attributed to "me" or to channel.
+  --nicks-order <order>     Order of nicks in --list-nicks output and of
+                             nick groups in --sort-by-nick output: count or
+                             name. [default: count]
+  --overview                Print one line for every channel with matching
+                             messages: number of them, time of the last one
+                             and number of distinct participants. Channels
+                             with recent activity go first.
+  --active-days             Print number of distinct calendar days in local
+                             time with matching messages for every channel
+                             and in total, so one busy day doesn't outweigh
+                             sustained discussion.
+  --count-by-direction      Print number of sent, received and info messages
+                             among matching ones instead of messages, per
+                             channel with --group-by-channel. Info messages
+                             are counted only with --include-info.
+  --age-buckets             Print number of matching messages by their age
+                             instead of messages.
+  --age-buckets-spec <s>    Comma-separated ascending upper bounds of ages
+                             for --age-buckets; the last bucket contains all
+                             older messages. [default: 1h,24h,168h]
+  --attachments             Print files, shared in matching messages, instead
+                             of messages: time, channel, file name and URL.
+                             Message is considered to be file share, if it
+                             consists of single URL only, which is how file
+                             uploads are sent by XMPP clients.
+  --output <file>           Write output into specified file instead of
+                             stdout. Colors are disabled in file output, unless
+                             they are forced by --force-color.
+  --gzip-output             Compress --output file with gzip.
+  --output-encoding <enc>   Write output in specified encoding, like latin1.
+                             [default: utf-8]
+  --output-replacement <s>  Replacement for characters, which can't be
+                             represented in --output-encoding. [default: ?]
+  --flatten                 Print consecutive messages, received from the same
+                             sender within --flatten-gap, as single message.
+  --flatten-gap <time>      Maximal time between messages, which are printed
+                             as single message by --flatten. [default: 2m]
+  --merge-adjacent-info     Print runs of consecutive info messages of the
+                             same channel, like presence changes, which are
+                             printed with --include-info, as single summary
+                             line.
+  --info-merge-gap <time>   Maximal time between adjacent info messages,
+                             which are merged by --merge-adjacent-info.
+                             [default: 1m]
+  --info-merge-min <n>      Minimal number of info messages in run, which is
+                             merged by --merge-adjacent-info. [default: 3]
+  --expand-merged-info      Print merged info messages under summary line.
+  --wrap                    Wrap printed lines at terminal width, indenting
+                             continuation lines. Lines aren't wrapped, if
+                             output is not terminal.
+  --width <n>               Wrap printed lines at specified width, even if
+                             output is not terminal. Implies --wrap.
+  --separator <string>      Print specified string between messages instead
+                             of empty line.
+  --no-separator            Don't print anything between messages.
+  --json                    Print matching messages as JSON objects, one per
+                             line, or report in JSON format.
+  --format <template>       Print every matching message using Go template,
+                             like '{{.Time}} {{.Nick}}: {{.Text}}'. Same
+                             fields as in --json output are available: ID,
+                             Channel, File, Label, Time, Direction, Nick,
+                             Status, Text, Offset and Length.
+  --template-file <file>    Same as --format, but read template from file.
+  --markdown                Print every matching message as Markdown
+                             blockquote with bold time and sender header,
+                             ready to paste into issues or wikis. Special
+                             characters are escaped and pasted code is
+                             fenced.
+  --json-schema             Print JSON Schema of messages in --json output
+                             and exit.
+  --show-offsets            Prefix every printed message with file name,
+                             byte offset and size of message in that file,
+                             in form of <file>:<offset>:<size>.
+  --decrypt-map <file>      File with plaintext of encrypted messages, like
+                             OMEMO or OTR ones, which are stored in history
+                             as placeholders, in form of <id>=<text> lines,
+                             where <id> is printed by --with-id. Newlines in
+                             text are written as \n. Plaintext is matched
+                             and printed instead of placeholder and marked
+                             as decrypted.
+  --resolve-jids            Print names instead of sender JIDs, using
+                             mapping from --jid-map file. Senders, which are
+                             not in file, are printed as is.
+  --jid-map <file>          File with lines in form of <jid>=<name>. JID
+                             without resource maps all its resources.
+                             Defaults to $HOME/.mcabber/jids.
+  --with-id                 Prefix every printed message with its ID, which is
+                             also included in --json output. ID is first 12
+                             hex digits of SHA-256 of channel name, message
+                             time in UTC in RFC 3339 format, direction (MR,
+                             MS or MI) and message text without formatting,
+                             joined by newlines.
+  --export-html <file>      Write matching messages to specified file as
+                             HTML page instead of printing them.
+  --limit <n>               Output at most specified number of messages.
+                             Which messages are output depends on ordering:
+                             by default, first found matches in order of
+                             files and messages in them; the earliest
+                             matches by time with --merge, --chronological
+                             or --preserve-order; the most relevant matches
+                             with --sort-by-relevance.
+  --preserve-order          With --limit, output the earliest matches by time
+                             in chronological order, instead of first found
+                             ones. At most --limit messages are kept in
+                             memory until search is finished.
+  --merge                   Print matching messages from all channels in
+                             chronological order, prefixed with channel name.
+                             All history files are read at once, assuming
+                             that every file is ordered by time; if it's not
+                             so, or if --dedup-cross-channel or --archive
+                             is used, all matches are kept in memory until
+                             search is finished.
+  --chronological           Print matching messages from all selected files
+                             in chronological order without channel prefix,
+                             like --merge. Useful for channel history, split
+                             into rotated files.
+  --dedup-cross-channel     With --merge, print only first of messages with
+                             the same text, posted to several channels within
+                             few seconds, like bridged ones, noting other
+                             channels.
+  --group-by-channel        Print matching messages under header with name of
+                             channel they belong to.
+  --count-in-header         With --group-by-channel, also print number of
+                             matching messages in channel header. Matches of
+                             one channel are kept in memory until all its
+                             files are searched.
+  --fair                    Distribute --limit between channels round-robin,
+                             so every channel with matches gets its share of
+                             output. At most --limit messages are kept in
+                             memory until search is finished.
+  --time-gap-markers        Print marker line between printed messages of the
+                             same channel, written more than gap threshold
+                             apart, like "··· 3h 20m later ···". Not printed
+                             in JSON and other structured output.
+  --gap-threshold <gap>     Minimum gap between messages to print marker.
+                             [default: 1h]
+  --gap-marker-format <s>   Marker line, where %s is replaced by gap.
+                             [default: ··· %s later ···]
+  --replay                  Print matching messages with delays, proportional
+                             to gaps between them, like conversation is
+                             happening right now. Makes sense for single
+                             channel or with --chronological.
+  --replay-speed <factor>   Speed up replay by specified factor, like 60 for
+                             minute of history per second. [default: 1]
+  --replay-max-delay <max>  Maximum delay between messages in replay; zero
+                             means no limit. [default: 5s]
+  --sort-by-nick            Print matching messages grouped by sender under
+                             header with nick, chronologically within group.
+                             All matches are kept in memory until search is
+                             finished.
+  --sort-by-relevance       Output messages ordered by relevance: how many of
+                             filter terms message contains, how dense matches
+                             are and how recent message is. All matches are
+                             kept in memory until search is finished.
 `
 
 type (
@@ -58,7 +576,7 @@ type Header struct {
 
 func main() {
 	args, err := docopt.Parse(
-		os.ExpandEnv(usage),
+		usage,
 		nil,
 		true,
 		"mcabber-history "+version,
@@ -68,158 +586,710 @@ func main() {
 		panic(err)
 	}
 
+	setEnvDefaults(args, !args["--no-expand-env"].(bool))
+
+	err = setupLogging(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = setupErrors(args)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = setupOutput(args)
+	if err != nil {
+		closeOutput()
+		fatal(err)
+	}
+
 	switch {
 	case args["-S"].(bool):
 		err = search(args)
+
+	case args["--dump-parsed"].(bool):
+		err = dumpParsed(args)
+
+	case args["--interactive"].(bool):
+		err = interactive(args)
+
+	case args["--browse"].(bool):
+		err = browse(args)
+
+	case args["--dump-index"].(bool):
+		err = dumpIndex(args)
+
+	case args["--rebuild-index"].(bool):
+		err = rebuildIndex(args)
+
+	case args["--at"] != nil:
+		err = printMessageAt(args)
+
+	case args["--json-schema"].(bool):
+		err = printJSONSchema()
+	}
+
+	// Output is closed on errors too, so compressed file, written so far,
+	// stays readable.
+	closeErr := closeOutput()
+
+	if err == errNoMatches {
+		os.Exit(1)
+	}
+
+	if err == nil {
+		err = closeErr
 	}
 
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 }
 
 func search(args map[string]interface{}) error {
-	files, err := filepath.Glob(
-		args["--path"].(string) + "/" +
-			args["<channel>"].(string) + "*",
-	)
+	started := time.Now()
+
+	searcher, err := newSearcher(args, args["<filter>"].([]string))
 	if err != nil {
-		return ser.Errorf(
-			err,
-			"can't obtain files list for %q",
-			args["<channel>"].(string),
-		)
+		return err
 	}
 
-	if len(files) == 0 {
-		return ser.Errorf(
-			err,
-			"no history files found in %q (%q)",
-			args["--path"].(string),
-			args["<channel>"].(string),
+	report, err := newReport(args)
+	if err != nil {
+		return err
+	}
+
+	if report != nil {
+		searcher.Collect = report.Add
+	}
+
+	jobs, err := strconv.Atoi(args["--jobs"].(string))
+	if err != nil || jobs <= 0 {
+		return fmt.Errorf(
+			"can't parse jobs count %q: should be positive number",
+			args["--jobs"].(string),
 		)
 	}
 
-	expression := `(?si)` + strings.Join(args["<filter>"].([]string), `.*`)
+	// Unless --fail-fast is used, errors in history files are reported
+	// only after search is finished, so messages from other files are not
+	// lost.
+	switch {
+	case searcher.IsStreaming() && args["--archive"] == nil && !isStdin(args):
+		err = mergeHistory(args, searcher.SearchMerged)
+
+	case jobs > 1:
+		parallel := newParallelSearch(searcher, jobs)
+
+		err = walkHistory(args, parallel.Search)
+
+		waitErr := parallel.Wait()
+		if err == nil {
+			err = waitErr
+		}
+
+	default:
+		err = walkHistory(args, searcher.Search)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = searcher.Flush()
+	if err != nil {
+		return err
+	}
+
+	if args["--follow"].(bool) {
+		if report != nil {
+			return fmt.Errorf("--follow can't be used with reports")
+		}
+
+		return follow(args, searcher)
+	}
+
+	if report != nil {
+		err = report.Print(args["--json"].(bool))
+		if err != nil {
+			return err
+		}
+	}
+
+	if args["--stats"].(bool) {
+		stats.Print()
+	}
+
+	if searcher.Anonymizer != nil && args["--anonymize-map"].(bool) {
+		err = searcher.Anonymizer.PrintMap(os.Stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fileErrors.Err()
+	if err != nil {
+		return err
+	}
+
+	if args["--touch-since-file"].(bool) {
+		err = touchSinceFile(args, started)
+		if err != nil {
+			return err
+		}
+	}
+
+	if value, ok := args["--at-time"].(string); ok && searcher.emitted == 0 {
+		return fmt.Errorf("no messages found at %q", value)
+	}
+
+	if total, ok := report.(*TotalReport); ok && total.Count == 0 {
+		return errNoMatches
+	}
+
+	return nil
+}
+
+// newSearcher creates searcher, configured by command line arguments, which
+// matches messages against specified filter terms.
+func newSearcher(
+	args map[string]interface{},
+	terms []string,
+) (*Searcher, error) {
+	var (
+		proximity *ProximityMatcher
+		sequence  = terms
+		relevant  = terms
+	)
+
+	if value, ok := args["--near"].(string); ok {
+		distance, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse distance %q: %s", value, err)
+		}
+
+		proximity, err = newProximityMatcher(terms, distance)
+		if err != nil {
+			return nil, err
+		}
+
+		sequence = nil
+	}
+
+	if args["--fixed-strings"].(bool) {
+		quoted := []string{}
+		for _, term := range terms {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+
+		relevant = quoted
+
+		if sequence != nil {
+			sequence = quoted
+		}
+	}
+
+	// Every term is matched as is, so spaces in quoted term already match
+	// only spaces, but separate terms are matched as loose sequence.
+	if args["--phrase"].(bool) && len(sequence) > 0 {
+		sequence = []string{strings.Join(sequence, " ")}
+	}
+
+	expression := `(?si)` + strings.Join(sequence, `.*`)
+	if args["--exact-match"].(bool) {
+		expression = `(?si)\A(?:` + strings.Join(sequence, `.*`) + `)\z`
+	}
+
 	filter, err := regexp.Compile(expression)
 	if err != nil {
-		return ser.Errorf(
+		return nil, ser.Errorf(
 			err,
 			"can't compile regexp %q",
 			expression,
 		)
 	}
 
-	since, err := time.ParseDuration(args["--since"].(string))
-	if err != nil {
-		return fmt.Errorf(
-			"can't parse time duration %q: %s",
-			args["--since"].(string), err,
+	var explainer *MatchExplainer
+	if args["--explain-match"].(bool) && len(sequence) > 0 {
+		explainer, err = newMatchExplainer(
+			sequence,
+			args["--exact-match"].(bool),
 		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var highlighter *Highlighter
+	if args["--highlight"].(bool) || args["--max-highlights"] != nil {
+		max := 0
+		if value, ok := args["--max-highlights"].(string); ok {
+			max, err = strconv.Atoi(value)
+			if err != nil || max <= 0 {
+				return nil, fmt.Errorf(
+					"can't parse highlights count %q: should be positive number",
+					value,
+				)
+			}
+		}
+
+		highlighter, err = newHighlighter(sequence, max)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now, err := parseAsOf(args)
+	if err != nil {
+		return nil, err
 	}
 
-	ignoredChannels, _ := args["--ignore-channels"].(string)
+	since, err := parseSince(args, now)
+	if err != nil {
+		return nil, err
+	}
 
-	separator := false
+	// Diff report needs messages from baseline window too, it splits them
+	// by --since itself.
+	if args["--diff"].(bool) {
+		since, err = parseBaselineSince(args, since, now)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	for _, file := range files {
-		ignore := false
+	var until time.Time
+	if args["--as-of"] != nil {
+		until = now
+	}
 
-		if ignoredChannels != "" {
-			for _, name := range strings.Split(ignoredChannels, ",") {
-				if strings.HasPrefix(filepath.Base(file), name) {
-					ignore = true
-				}
-			}
+	if value, ok := args["--at-time"].(string); ok {
+		moment, err := parseAtTime(value)
+		if err != nil {
+			return nil, err
 		}
 
-		if ignore {
-			continue
+		since = moment.Add(-atTimeTolerance)
+		until = moment.Add(atTimeTolerance)
+	}
+
+	var beforeTime, afterTime time.Duration
+
+	if value, ok := args["--before-time"].(string); ok {
+		beforeTime, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
+			)
 		}
+	}
 
-		handle, err := os.Open(file)
+	if value, ok := args["--after-time"].(string); ok {
+		afterTime, err = time.ParseDuration(value)
 		if err != nil {
-			return ser.Errorf(
-				err,
-				"can't open history file %q",
-				file,
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
 			)
 		}
+	}
 
-		scanner := bufio.NewScanner(handle)
-		for scanner.Scan() {
-			header, err := parseHeader(scanner.Text())
+	var markers ReadMarkers
+	if args["--since-last-read"].(bool) {
+		markers, err = readReadMarkers(args["--read-markers"].(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	searcher := &Searcher{
+		Filter:      filter,
+		Proximity:   proximity,
+		Since:       since,
+		ReadMarkers: markers,
+		Until:       until,
+		BeforeTime:  beforeTime,
+		AfterTime:   afterTime,
+		ExactMatch:  args["--exact-match"].(bool),
+		Explain:     explainer,
+		Highlight:   highlighter,
+	}
+
+	if value, ok := args["--after-silence"].(string); ok {
+		searcher.AfterSilence, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
+			)
+		}
+	}
+
+	if value, ok := args["--weekday"].(string); ok {
+		searcher.Weekdays, err = parseWeekdays(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if args["--fold-quotes"].(bool) {
+		searcher.QuotePrefix = args["--quote-prefix"].(string)
+	}
+
+	for name, target := range map[string]*int{
+		"--lines-min": &searcher.LinesMin,
+		"--lines-max": &searcher.LinesMax,
+	} {
+		if value, ok := args[name].(string); ok {
+			*target, err = strconv.Atoi(value)
 			if err != nil {
-				return ser.Errorf(
-					err,
-					"line malformed: %q (file %q)",
-					scanner.Text(),
-					file,
+				return nil, fmt.Errorf(
+					"can't parse %s value %q: %s",
+					name, value, err,
 				)
 			}
+		}
+	}
 
-			ignore := false
+	if args["--merge-adjacent-info"].(bool) {
+		window, err := time.ParseDuration(args["--info-merge-gap"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--info-merge-gap"].(string), err,
+			)
+		}
 
-			if time.Since(header.Time).Seconds() > since.Seconds() {
-				ignore = true
-			}
+		threshold, err := strconv.Atoi(args["--info-merge-min"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse info messages count %q: %s",
+				args["--info-merge-min"].(string), err,
+			)
+		}
 
-			var (
-				direction string
+		searcher.MergeAdjacentInfo = &InfoMerger{
+			Window:    window,
+			Threshold: threshold,
+			Expand:    args["--expand-merged-info"].(bool),
+		}
+	}
+
+	if args["--flatten"].(bool) {
+		searcher.Flatten = true
+		searcher.FlattenGap, err = time.ParseDuration(
+			args["--flatten-gap"].(string),
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--flatten-gap"].(string), err,
 			)
+		}
+	}
 
-			switch header.Direction {
-			case DirectionRecv:
-				direction = color.GreenString(">>>")
+	searcher.Width, err = parseWrapWidth(args)
+	if err != nil {
+		return nil, err
+	}
 
-			case DirectionSend:
-				direction = color.RedString("<<<")
+	if value, ok := args["--separator"].(string); ok {
+		searcher.Separator = value
+	}
 
-			case DirectionInfo:
-				ignore = true
-			}
+	searcher.NoSeparator = args["--no-separator"].(bool)
+	searcher.OutputNull = args["--output-null"].(bool)
+	searcher.ContextSeparator = args["--context-separator"].(string)
+	searcher.NoContextSeparator = args["--no-context-separator"].(bool)
+	searcher.JSON = args["--json"].(bool)
+	searcher.Markdown = args["--markdown"].(bool)
+
+	searcher.Template, err = parseTemplate(args)
+	if err != nil {
+		return nil, err
+	}
+	searcher.ShowOffsets = args["--show-offsets"].(bool)
+	searcher.WithID = args["--with-id"].(bool)
+
+	// Escaping, decryption and normalization of whitespace change text,
+	// which is matched, so index of history files can't be used with them.
+	if args["--bloom-prefilter"].(bool) && !args["--binary-safe"].(bool) &&
+		args["--decrypt-map"] == nil && !args["--normalize-whitespace"].(bool) {
+		searcher.Prefilter = newBloomPrefilter(
+			Index{Dir: args["--index-dir"].(string)},
+			sequence,
+		)
+	}
+
+	if path, ok := args["--decrypt-map"].(string); ok {
+		searcher.Decrypt, err = readDecryptMap(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if args["--resolve-jids"].(bool) {
+		searcher.JIDs, err = readJIDMap(args["--jid-map"].(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	searcher.Plain = args["--plain"].(bool)
+	searcher.ISOTimestamps = args["--iso-timestamps"].(bool)
+
+	if args["--anonymize"].(bool) {
+		searcher.Anonymizer, err = newAnonymizer(
+			args["--anonymize-only"].(string),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	searcher.LineRegexp = args["--line-regexp"].(bool) ||
+		args["--matching-lines-only"].(bool)
+	searcher.MatchingLinesOnly = args["--matching-lines-only"].(bool)
 
-			var (
-				lines = []string{
-					fmt.Sprintf("%s %s %s",
-						direction,
-						color.BlueString(header.Time.Format(time.ANSIC)),
-						header.Message,
-					),
-				}
+	searcher.CorrectionsOnly = args["--corrections-only"].(bool)
+	searcher.RepliesOnly = args["--replies-only"].(bool)
+	searcher.ReplyQuotePrefix = args["--quote-prefix"].(string)
+
+	if args["--code"].(bool) {
+		minLines, err := strconv.Atoi(args["--code-min-lines"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse code lines count %q: %s",
+				args["--code-min-lines"].(string), err,
 			)
+		}
 
-			for i := 0; i < header.Length; i++ {
-				if !scanner.Scan() {
-					return ser.Errorf(
-						err,
-						"not enough lines in message (%d)",
-						header.Length,
-					)
-				}
+		searcher.Code = &CodeDetector{
+			MinLines: minLines,
+			Fences:   !args["--no-code-fences"].(bool),
+		}
+	}
 
-				lines = append(lines, scanner.Text())
-			}
+	nick, _ := args["--highlight-nick"].(string)
+	if nick == "" {
+		nick = os.Getenv("MCABBER_NICK")
+	}
+
+	if nick != "" {
+		searcher.Mention = newMentionMatcher(nick)
+	}
 
-			if ignore {
-				continue
+	searcher.IgnoreOwn = args["--ignore-own"].(bool)
+	searcher.OwnNick = nick
+
+	searcher.MentionsOnly = args["--mentions-only"].(bool)
+	if searcher.MentionsOnly && searcher.Mention == nil {
+		return nil, fmt.Errorf(
+			"--mentions-only requires --highlight-nick or MCABBER_NICK",
+		)
+	}
+	if nicks, ok := args["--mentions"].(string); ok {
+		for _, nick := range strings.Split(nicks, ",") {
+			nick = strings.TrimSpace(nick)
+			if nick != "" {
+				searcher.Mentions = append(
+					searcher.Mentions,
+					newMentionMatcher(nick),
+				)
 			}
+		}
+	}
 
-			message := strings.Join(lines, "\n")
+	searcher.MentionsAny = args["--mentions-any"].(bool)
+
+	if domain, ok := args["--url-domain"].(string); ok {
+		searcher.URLDomain, err = newURLDomainMatcher(domain)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			if filter.MatchString(message) {
-				if separator {
-					fmt.Println()
-				}
+	searcher.IncludeInfo = args["--include-info"].(bool)
 
-				fmt.Println(message)
+	if args["--merge-info-into-context"].(bool) {
+		searcher.MergeInfo = true
 
-				separator = true
+		searcher.InfoWindow, err = time.ParseDuration(
+			args["--info-window"].(string),
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--info-window"].(string), err,
+			)
+		}
+	}
+
+	if status, ok := args["--status"].(string); ok {
+		status = strings.ToLower(status)
+		if _, ok := presenceStatuses[status]; !ok {
+			return nil, fmt.Errorf("unknown presence status %q", status)
+		}
+
+		searcher.IncludeInfo = true
+		searcher.Status = presenceStatuses[status]
+	}
+	searcher.StripFormatting = args["--strip-formatting"].(bool)
+	searcher.Raw = args["--raw"].(bool)
+	searcher.BinarySafe = args["--binary-safe"].(bool)
+	searcher.NormalizeWhitespace = args["--normalize-whitespace"].(bool)
+	searcher.NormalizeDisplay = args["--normalize-display"].(bool)
+
+	if command, ok := args["--exec"].(string); ok {
+		searcher.Exec = &Executor{
+			Command: command,
+			Abort:   args["--exec-abort"].(bool),
+		}
+	}
+
+	if command, ok := args["--exec-batch"].(string); ok {
+		searcher.Exec = &Executor{
+			Command: command,
+			Batch:   true,
+			Abort:   args["--exec-abort"].(bool),
+		}
+	}
+
+	if value, ok := args["--limit"].(string); ok {
+		searcher.Limit, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse limit %q: %s", value, err)
+		}
+	}
+
+	searcher.Merge = args["--merge"].(bool)
+	searcher.Chronological = args["--chronological"].(bool)
+	searcher.DedupCrossChannel = args["--dedup-cross-channel"].(bool)
+	searcher.Fair = args["--fair"].(bool)
+	searcher.GroupByChannel = args["--group-by-channel"].(bool)
+	searcher.CountInHeader = args["--count-in-header"].(bool)
+
+	if args["--time-gap-markers"].(bool) {
+		threshold, err := time.ParseDuration(args["--gap-threshold"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--gap-threshold"].(string), err,
+			)
+		}
+
+		searcher.GapMarkers = &GapMarkers{
+			Threshold: threshold,
+			Format:    args["--gap-marker-format"].(string),
+		}
+	}
+
+	if args["--replay"].(bool) {
+		value := args["--replay-speed"].(string)
+
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed <= 0 {
+			return nil, fmt.Errorf("can't parse replay speed %q", value)
+		}
+
+		delay, err := time.ParseDuration(args["--replay-max-delay"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--replay-max-delay"].(string), err,
+			)
+		}
+
+		searcher.Replay = &Replayer{
+			Speed:    speed,
+			MaxDelay: delay,
+		}
+	}
+
+	if args["--unanswered"].(bool) {
+		question, err := regexp.Compile(args["--question-regexp"].(string))
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't compile question regexp %q",
+				args["--question-regexp"].(string),
+			)
+		}
+
+		window, err := time.ParseDuration(args["--reply-window"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--reply-window"].(string), err,
+			)
+		}
+
+		searcher.Unanswered = &UnansweredDetector{
+			Question: question,
+			Window:   window,
+		}
+	}
+
+	if pattern, ok := args["--context-requires"].(string); ok {
+		expression, err := regexp.Compile(`(?si)` + pattern)
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't compile context regexp %q",
+				pattern,
+			)
+		}
+
+		searcher.ContextGate = &ContextGate{Pattern: expression}
+
+		value := args["--context-window"].(string)
+		if count, err := strconv.Atoi(value); err == nil && count > 0 {
+			searcher.ContextGate.Messages = count
+		} else {
+			searcher.ContextGate.Window, err = time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"can't parse context window %q: should be number of "+
+						"messages or time duration",
+					value,
+				)
 			}
 		}
 	}
 
-	return nil
+	searcher.SortByNick = args["--sort-by-nick"].(bool)
+	searcher.NicksByName = args["--nicks-order"].(string) == "name"
+
+	searcher.PreserveOrder = args["--preserve-order"].(bool)
+	if searcher.PreserveOrder {
+		switch {
+		case searcher.Limit == 0:
+			return nil, fmt.Errorf("--preserve-order requires --limit")
+
+		case searcher.Fair:
+			return nil, fmt.Errorf("--preserve-order can't be used with --fair")
+
+		case args["--sort-by-relevance"].(bool):
+			return nil, fmt.Errorf(
+				"--preserve-order can't be used with --sort-by-relevance",
+			)
+		}
+	}
+
+	if args["--sort-by-relevance"].(bool) {
+		searcher.Relevance, err = newRelevanceScorer(
+			relevant,
+			now.Sub(since),
+			now,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return searcher, nil
 }
 
 func parseHeader(line string) (*Header, error) {
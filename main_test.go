@@ -0,0 +1,118 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docopt/docopt-go"
+)
+
+// writeHistory writes history file with specified lines into directory and
+// returns its path.
+func writeHistory(
+	t testing.TB,
+	dir string,
+	name string,
+	lines ...string,
+) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeArchive writes gzipped tarball with single history file, which has
+// specified name and content.
+func writeArchive(t *testing.T, path string, name string, content string) {
+	t.Helper()
+
+	handle, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer handle.Close()
+
+	compressor := gzip.NewWriter(handle)
+	archive := tar.NewWriter(compressor)
+
+	err = archive.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+	})
+	if err == nil {
+		_, err = archive.Write([]byte(content))
+	}
+
+	if err == nil {
+		err = archive.Close()
+	}
+
+	if err == nil {
+		err = compressor.Close()
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// parseTestArgs parses command line arguments like main does. HOME is set to
+// temporary directory, so defaults, like index directory, don't touch real
+// home directory.
+func parseTestArgs(t testing.TB, argv ...string) map[string]interface{} {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	args, err := docopt.Parse(usage, argv, false, "", false, false)
+	if err != nil {
+		t.Fatalf("can't parse arguments %q: %s", argv, err)
+	}
+
+	setEnvDefaults(args, !args["--no-expand-env"].(bool))
+
+	err = setupErrors(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return args
+}
+
+// runSearch runs search with specified command line arguments, which should
+// not include -S, and returns printed output.
+func runSearch(t *testing.T, argv ...string) (string, error) {
+	t.Helper()
+
+	args := parseTestArgs(t, append([]string{"-S", "--no-color"}, argv...)...)
+
+	buffer := &bytes.Buffer{}
+
+	stdout = buffer
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	err := search(args)
+
+	return buffer.String(), err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markdownEscaper escapes characters, which have special meaning in inline
+// Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"#", `\#`,
+	"|", `\|`,
+	"~", `\~`,
+)
+
+// markdownListMarker matches line beginnings, which Markdown takes for list
+// items.
+var markdownListMarker = regexp.MustCompile(`^(\s*)([-+]|\d+[.)])(\s|$)`)
+
+// markdownCode detects code in messages, for which detection was not
+// requested by --code, so pasted code is fenced anyway.
+var markdownCode = &CodeDetector{
+	MinLines: 3,
+	Fences:   true,
+}
+
+// escapeMarkdown escapes line of message text, so it's rendered literally.
+func escapeMarkdown(line string) string {
+	line = markdownEscaper.Replace(line)
+
+	return markdownListMarker.ReplaceAllStringFunc(
+		line,
+		func(marker string) string {
+			index := strings.IndexAny(marker, "-+.)")
+
+			return marker[:index] + `\` + marker[index:]
+		},
+	)
+}
+
+// formatMarkdownMessage renders message as Markdown blockquote with bold
+// time and sender header. Message lines are escaped, unless message looks
+// like code, in which case its text is fenced instead, or kept as is, if
+// it's already fenced.
+func formatMarkdownMessage(file HistoryFile, message *Message) string {
+	record := newJSONMessage(file, message)
+
+	header := record.Time.Format("Mon Jan _2 15:04:05 2006")
+	if participant := extractParticipant(file, message); participant != "" {
+		header += " " + participant
+	}
+
+	text := message.Content()
+	if record.Nick == "" {
+		text = strings.TrimSpace(message.Text())
+	}
+
+	code := record.Code
+	if code == "" {
+		code = markdownCode.Detect(message)
+	}
+
+	var lines []string
+
+	switch code {
+	case CodeFence:
+		lines = strings.Split(text, "\n")
+
+	case CodeIndent:
+		lines = append([]string{"```"}, strings.Split(text, "\n")...)
+		lines = append(lines, "```")
+
+	default:
+		// Lines are ended with two spaces, so they are rendered as line
+		// breaks instead of being joined into paragraph.
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, escapeMarkdown(strings.TrimRight(line, " "))+"  ")
+		}
+
+		lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], "  ")
+	}
+
+	quoted := []string{
+		fmt.Sprintf("> **%s**", escapeMarkdown(header)),
+		">",
+	}
+
+	for _, line := range lines {
+		if line == "" {
+			quoted = append(quoted, ">")
+		} else {
+			quoted = append(quoted, "> "+line)
+		}
+	}
+
+	return strings.Join(quoted, "\n")
+}
+
+// printMarkdown prints message as Markdown blockquote, separating it from
+// previous one by blank line.
+func (searcher *Searcher) printMarkdown(file HistoryFile, message *Message) {
+	if searcher.JIDs != nil {
+		message = searcher.JIDs.Resolve(message)
+	}
+
+	if searcher.separator {
+		fmt.Fprintln(stdout)
+	}
+
+	fmt.Fprintln(stdout, formatMarkdownMessage(file, message))
+
+	searcher.separator = true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// ReadMarkers maps channel names to time of the last read message.
+type ReadMarkers map[string]time.Time
+
+// readReadMarkers reads read markers from file with lines in form of
+// <channel> <time>, where time is in format of history file headers, like
+// 20060102T15:04:05Z, skipping blank lines and comments, starting with #.
+// Missing file means no markers.
+func readReadMarkers(path string) (ReadMarkers, error) {
+	markers := ReadMarkers{}
+
+	handle, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return markers, nil
+		}
+
+		return nil, ser.Errorf(
+			err,
+			"can't open read markers %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, ser.Errorf(
+				nil,
+				"can't parse read markers %q line %q: should be <channel> <time>",
+				path,
+				line,
+			)
+		}
+
+		moment, err := time.Parse("20060102T15:04:05Z", fields[1])
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't parse read markers %q line %q",
+				path,
+				line,
+			)
+		}
+
+		markers[strings.ToLower(fields[0])] = moment
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't read read markers %q",
+			path,
+		)
+	}
+
+	return markers, nil
+}
+
+// Since returns time of the last read message of channel, which file belongs
+// to, or specified fallback time, if there is no marker for channel.
+func (markers ReadMarkers) Since(file HistoryFile, fallback time.Time) time.Time {
+	channel := rotationSuffix.ReplaceAllString(filepath.Base(file.Name), "")
+
+	if moment, ok := markers[strings.ToLower(channel)]; ok {
+		return moment
+	}
+
+	return fallback
+}
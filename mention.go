@@ -0,0 +1,37 @@
+package main
+
+import (
+	"regexp"
+)
+
+// MentionMatcher detects received messages, which mention specified nick as
+// a word, like "nick: hi", "@nick" or "ask nick".
+type MentionMatcher struct {
+	Nick string
+
+	expression *regexp.Regexp
+}
+
+func newMentionMatcher(nick string) *MentionMatcher {
+	return &MentionMatcher{
+		Nick: nick,
+		expression: regexp.MustCompile(
+			`(?i)(?:^|[^\pL\pN_-])@?` + regexp.QuoteMeta(nick) +
+				`(?:$|[^\pL\pN_-])`,
+		),
+	}
+}
+
+// Match reports whether message is received one and mentions nick.
+func (matcher *MentionMatcher) Match(message *Message) bool {
+	if message.Header.Direction != DirectionRecv {
+		return false
+	}
+
+	return matcher.Mentions(message)
+}
+
+// Mentions reports whether message of any direction mentions nick.
+func (matcher *MentionMatcher) Mentions(message *Message) bool {
+	return matcher.expression.MatchString(message.Content())
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"container/heap"
+	"io"
+	"iter"
+	"log/slog"
+)
+
+// mergeSource is a history file, which matching messages are read from one
+// by one during streaming merge.
+type mergeSource struct {
+	file    HistoryFile
+	next    func() (*Message, bool)
+	stop    func()
+	message *Message
+	err     error
+}
+
+// mergeQueue is a min-heap of sources, ordered by time of their pending
+// messages.
+type mergeQueue []*mergeSource
+
+func (queue mergeQueue) Len() int {
+	return len(queue)
+}
+
+func (queue mergeQueue) Less(i, j int) bool {
+	return queue[i].message.Header.Time.Before(queue[j].message.Header.Time)
+}
+
+func (queue mergeQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+}
+
+func (queue *mergeQueue) Push(source interface{}) {
+	*queue = append(*queue, source.(*mergeSource))
+}
+
+func (queue *mergeQueue) Pop() interface{} {
+	var (
+		old    = *queue
+		source = old[len(old)-1]
+	)
+
+	*queue = old[:len(old)-1]
+
+	return source
+}
+
+// IsStreaming reports whether matching messages can be merged from all files
+// in chronological order without buffering all of them.
+func (searcher *Searcher) IsStreaming() bool {
+	return (searcher.Merge || searcher.Chronological) &&
+		searcher.Relevance == nil && !searcher.DedupCrossChannel
+}
+
+// SearchMerged searches all history files at once and outputs matching
+// messages in chronological order. Every history file is assumed to be
+// ordered by time, which is true for files, written by mcabber, so only one
+// pending match per file is kept in memory. If some file turns out to be
+// out of order, all remaining matches are buffered and sorted on Flush.
+func (searcher *Searcher) SearchMerged(
+	files []HistoryFile,
+	readers []io.Reader,
+) error {
+	queue := &mergeQueue{}
+
+	for index := range files {
+		if searcher.Prefilter != nil &&
+			!searcher.Prefilter.MayContain(files[index]) {
+			continue
+		}
+
+		source := &mergeSource{
+			file: files[index],
+		}
+
+		walk := searcher.read(files[index], readers[index])
+
+		source.next, source.stop = iter.Pull(
+			func(yield func(*Message) bool) {
+				source.err = searcher.search(
+					source.file,
+					walk,
+					func(file HistoryFile, message *Message) error {
+						if !yield(message) {
+							return errStop
+						}
+
+						return nil
+					},
+				)
+			},
+		)
+
+		defer source.stop()
+
+		err := source.pull(queue)
+		if err != nil {
+			return err
+		}
+	}
+
+	for queue.Len() > 0 {
+		source := heap.Pop(queue).(*mergeSource)
+		message := source.message
+
+		err := searcher.output(source.file, message)
+		if err == errStop {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = source.pull(queue)
+		if err != nil {
+			return err
+		}
+
+		if source.message != nil &&
+			source.message.Header.Time.Before(message.Header.Time) {
+			slog.Warn(
+				"history file is not ordered by time, buffering matches",
+				"file", source.file.Name,
+			)
+
+			return searcher.bufferMerged(queue)
+		}
+	}
+
+	return nil
+}
+
+// pull reads next matching message of source and puts source back to queue,
+// if there is such message.
+func (source *mergeSource) pull(queue *mergeQueue) error {
+	message, ok := source.next()
+	if !ok {
+		source.message = nil
+
+		return source.err
+	}
+
+	source.message = message
+
+	heap.Push(queue, source)
+
+	return nil
+}
+
+// bufferMerged reads all remaining matches of queued sources into buffer,
+// which is sorted and printed on Flush.
+func (searcher *Searcher) bufferMerged(queue *mergeQueue) error {
+	for _, source := range *queue {
+		for source.message != nil {
+			searcher.scored = append(searcher.scored, scoredMessage{
+				file:    source.file,
+				message: source.message,
+			})
+
+			message, ok := source.next()
+			if !ok {
+				if source.err != nil {
+					return source.err
+				}
+
+				break
+			}
+
+			source.message = message
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// errMmapUnsupported is returned by mmapFile on platforms without mmap.
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// mappedFile reads history file, which is mapped into memory. Reading from
+// mapped region doesn't involve read syscall for every buffer.
+type mappedFile struct {
+	*bytes.Reader
+
+	handle *os.File
+	data   []byte
+}
+
+// mapHistoryFile returns reader of memory-mapped history file, which closes
+// handle, when closed itself. Handle is returned as is, if file can't be
+// mapped, like empty file or file on platform without mmap support.
+func mapHistoryFile(handle *os.File) io.ReadCloser {
+	stat, err := handle.Stat()
+	if err != nil || stat.Size() == 0 {
+		return handle
+	}
+
+	data, err := mmapFile(handle, stat.Size())
+	if err != nil {
+		slog.Debug(
+			"can't map history file, reading it as is",
+			"file", handle.Name(),
+			"error", err,
+		)
+
+		return handle
+	}
+
+	return &mappedFile{
+		Reader: bytes.NewReader(data),
+		handle: handle,
+		data:   data,
+	}
+}
+
+func (file *mappedFile) Close() error {
+	err := munmapFile(file.data)
+
+	closeErr := file.handle.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
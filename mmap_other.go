@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+func mmapFile(handle *os.File, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkRead benchmarks parsing of large history file, which is read
+// either with read syscalls or from memory-mapped region.
+func benchmarkRead(b *testing.B, mmap bool) {
+	lines := []string{}
+	for index := 0; index < 100000; index++ {
+		lines = append(lines, fmt.Sprintf(
+			"MR 20240102T10:%02d:%02dZ 001 <user%d> message number %d",
+			index/60%60, index%60, index%10, index,
+		), "second line of message body")
+	}
+
+	path := writeHistory(b, b.TempDir(), "work", lines...)
+
+	searcher, err := newSearcher(parseTestArgs(b, "-S", "work"), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	file := HistoryFile{Name: path}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		handle, err := openHistoryReader(file, 0, mmap)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		count := 0
+
+		err = searcher.read(file, handle)(func(*Message) error {
+			count++
+
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		handle.Close()
+
+		if count != len(lines)/2 {
+			b.Fatalf("got %d messages, want %d", count, len(lines)/2)
+		}
+	}
+}
+
+func BenchmarkReadScanner(b *testing.B) {
+	benchmarkRead(b, false)
+}
+
+func BenchmarkReadMmap(b *testing.B) {
+	benchmarkRead(b, true)
+}
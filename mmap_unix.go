@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(handle *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(
+		int(handle.Fd()),
+		0,
+		int(size),
+		syscall.PROT_READ,
+		syscall.MAP_SHARED,
+	)
+}
+
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
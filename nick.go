@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+)
+
+// extractNick returns nick of message sender for multi-user chat messages,
+// which are stored in form of "<nick> text". Empty string is returned for
+// messages without nick.
+func extractNick(message *Message) string {
+	text := message.Header.Message
+	if !strings.HasPrefix(text, "<") {
+		return ""
+	}
+
+	end := strings.Index(text, "> ")
+	if end < 0 {
+		if strings.HasSuffix(text, ">") {
+			end = len(text) - 1
+		} else {
+			return ""
+		}
+	}
+
+	return text[1:end]
+}
+
+// extractParticipant returns name of message sender: nick for multi-user
+// chat messages, "me" for sent private messages or channel name for
+// received ones. Empty string is returned for info messages.
+func extractParticipant(file HistoryFile, message *Message) string {
+	if nick := extractNick(message); nick != "" {
+		return nick
+	}
+
+	switch message.Header.Direction {
+	case DirectionSend:
+		return "me"
+
+	case DirectionRecv:
+		return file.Channel()
+	}
+
+	return ""
+}
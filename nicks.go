@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// NicksReport lists distinct senders of matching messages, ordered either by
+// number of messages or by nick.
+type NicksReport struct {
+	ByName bool
+
+	nicks map[string]*NickActivity
+}
+
+// NickActivity is a number of matching messages of single sender and times
+// of the first and the last of them.
+type NickActivity struct {
+	Nick      string    `json:"nick"`
+	Messages  int       `json:"messages"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func (report *NicksReport) Add(file HistoryFile, message *Message) error {
+	if report.nicks == nil {
+		report.nicks = map[string]*NickActivity{}
+	}
+
+	nick := extractParticipant(file, message)
+	if nick == "" {
+		return nil
+	}
+
+	activity, ok := report.nicks[nick]
+	if !ok {
+		activity = &NickActivity{
+			Nick:      nick,
+			FirstSeen: message.Header.Time,
+			LastSeen:  message.Header.Time,
+		}
+
+		report.nicks[nick] = activity
+	}
+
+	activity.Messages++
+
+	if message.Header.Time.Before(activity.FirstSeen) {
+		activity.FirstSeen = message.Header.Time
+	}
+
+	if message.Header.Time.After(activity.LastSeen) {
+		activity.LastSeen = message.Header.Time
+	}
+
+	return nil
+}
+
+func (report *NicksReport) Print(asJSON bool) error {
+	nicks := []*NickActivity{}
+	for _, activity := range report.nicks {
+		nicks = append(nicks, activity)
+	}
+
+	sort.Slice(nicks, func(i, j int) bool {
+		if !report.ByName && nicks[i].Messages != nicks[j].Messages {
+			return nicks[i].Messages > nicks[j].Messages
+		}
+
+		return nicks[i].Nick < nicks[j].Nick
+	})
+
+	if asJSON {
+		return printJSON(nicks)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "nick\tmessages\tfirst seen\tlast seen")
+
+	for _, activity := range nicks {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%s\t%s\n",
+			activity.Nick,
+			activity.Messages,
+			activity.FirstSeen.Format(time.ANSIC),
+			activity.LastSeen.Format(time.ANSIC),
+		)
+	}
+
+	return writer.Flush()
+}
+
+// nickGroup is a list of messages of single sender.
+type nickGroup struct {
+	nick     string
+	messages []scoredMessage
+}
+
+// groupByNick groups messages by sender, ordering messages of every sender
+// chronologically and groups either by number of messages or by nick.
+func groupByNick(messages []scoredMessage, byName bool) []nickGroup {
+	var (
+		groups = []nickGroup{}
+		index  = map[string]int{}
+	)
+
+	for _, message := range messages {
+		nick := extractParticipant(message.file, message.message)
+
+		position, ok := index[nick]
+		if !ok {
+			position = len(groups)
+			index[nick] = position
+
+			groups = append(groups, nickGroup{nick: nick})
+		}
+
+		groups[position].messages = append(
+			groups[position].messages,
+			message,
+		)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group.messages, func(i, j int) bool {
+			return group.messages[i].message.Header.Time.Before(
+				group.messages[j].message.Header.Time,
+			)
+		})
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if !byName && len(groups[i].messages) != len(groups[j].messages) {
+			return len(groups[i].messages) > len(groups[j].messages)
+		}
+
+		return groups[i].nick < groups[j].nick
+	})
+
+	return groups
+}
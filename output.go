@@ -0,0 +1,173 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/reconquest/ser-go"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// stdout is a writer, which all output should go to.
+var stdout io.Writer = os.Stdout
+
+// outputClosers are writers of --output file, which should be closed in
+// reverse order after all output is written.
+var outputClosers []io.Closer
+
+// setupOutput configures stdout and colors according to command line
+// arguments.
+func setupOutput(args map[string]interface{}) error {
+	path, toFile := args["--output"].(string)
+
+	if args["--gzip-output"].(bool) && !toFile {
+		return fmt.Errorf("--gzip-output requires --output")
+	}
+
+	if toFile {
+		handle, err := os.Create(path)
+		if err != nil {
+			return ser.Errorf(
+				err,
+				"can't create output file %q",
+				path,
+			)
+		}
+
+		stdout = handle
+		outputClosers = append(outputClosers, handle)
+
+		if args["--gzip-output"].(bool) {
+			compressor := gzip.NewWriter(handle)
+
+			stdout = compressor
+			outputClosers = append(outputClosers, compressor)
+		}
+	}
+
+	// Colors are already disabled by color package, if stdout is not
+	// terminal or NO_COLOR environment variable is set.
+	switch {
+	case args["--force-color"].(bool):
+		color.NoColor = false
+
+	case args["--no-color"].(bool), args["--plain"].(bool), toFile:
+		color.NoColor = true
+	}
+
+	name := args["--output-encoding"].(string)
+
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return nil
+	}
+
+	charset, err := ianaindex.IANA.Encoding(name)
+	if err != nil || charset == nil {
+		return fmt.Errorf("unsupported output encoding %q", name)
+	}
+
+	writer, err := newEncodingWriter(
+		stdout,
+		charset.NewEncoder(),
+		args["--output-replacement"].(string),
+	)
+	if err != nil {
+		return err
+	}
+
+	stdout = writer
+
+	return nil
+}
+
+// closeOutput closes --output file, flushing compressed data, if
+// --gzip-output is used.
+func closeOutput() error {
+	for index := len(outputClosers) - 1; index >= 0; index-- {
+		err := outputClosers[index].Close()
+		if err != nil {
+			return ser.Errorf(err, "can't close output file")
+		}
+	}
+
+	outputClosers = nil
+
+	return nil
+}
+
+// encodingWriter encodes UTF-8 text in specified encoding, replacing
+// characters, which can't be encoded, with replacement string.
+type encodingWriter struct {
+	writer      io.Writer
+	encoder     *encoding.Encoder
+	replacement []byte
+	encoded     map[rune][]byte
+	pending     []byte
+}
+
+func newEncodingWriter(
+	writer io.Writer,
+	encoder *encoding.Encoder,
+	replacement string,
+) (*encodingWriter, error) {
+	encoded, err := encoder.Bytes([]byte(replacement))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"replacement %q can't be represented in output encoding",
+			replacement,
+		)
+	}
+
+	return &encodingWriter{
+		writer:      writer,
+		encoder:     encoder,
+		replacement: encoded,
+		encoded:     map[rune][]byte{},
+	}, nil
+}
+
+func (writer *encodingWriter) Write(data []byte) (int, error) {
+	var (
+		text   = append(writer.pending, data...)
+		output = []byte{}
+	)
+
+	for len(text) > 0 {
+		if !utf8.FullRune(text) {
+			break
+		}
+
+		char, size := utf8.DecodeRune(text)
+		text = text[size:]
+
+		encoded, ok := writer.encoded[char]
+		if !ok {
+			var err error
+
+			encoded, err = writer.encoder.Bytes([]byte(string(char)))
+			if err != nil || char == utf8.RuneError {
+				encoded = writer.replacement
+			}
+
+			writer.encoded[char] = encoded
+		}
+
+		output = append(output, encoded...)
+	}
+
+	writer.pending = append([]byte{}, text...)
+
+	_, err := writer.writer.Write(output)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// OverviewReport summarizes every channel with matching messages: number of
+// messages, time of the last one and number of distinct participants.
+type OverviewReport struct {
+	channels map[string]*ChannelOverview
+	nicks    map[string]map[string]bool
+}
+
+// ChannelOverview is a summary of matching messages in single channel.
+type ChannelOverview struct {
+	Channel      string    `json:"channel"`
+	Messages     int       `json:"messages"`
+	LastActivity time.Time `json:"last_activity"`
+	Participants int       `json:"participants"`
+}
+
+func (report *OverviewReport) Add(file HistoryFile, message *Message) error {
+	if report.channels == nil {
+		report.channels = map[string]*ChannelOverview{}
+		report.nicks = map[string]map[string]bool{}
+	}
+
+	channel := file.Channel()
+	if file.Label != "" {
+		channel = file.Label + "/" + channel
+	}
+
+	overview, ok := report.channels[channel]
+	if !ok {
+		overview = &ChannelOverview{Channel: channel}
+
+		report.channels[channel] = overview
+		report.nicks[channel] = map[string]bool{}
+	}
+
+	overview.Messages++
+
+	if message.Header.Time.After(overview.LastActivity) {
+		overview.LastActivity = message.Header.Time
+	}
+
+	nick := extractParticipant(file, message)
+	if nick != "" && !report.nicks[channel][nick] {
+		report.nicks[channel][nick] = true
+		overview.Participants++
+	}
+
+	return nil
+}
+
+func (report *OverviewReport) Print(asJSON bool) error {
+	overviews := []*ChannelOverview{}
+	for _, overview := range report.channels {
+		overviews = append(overviews, overview)
+	}
+
+	sort.Slice(overviews, func(i, j int) bool {
+		if overviews[i].LastActivity.Equal(overviews[j].LastActivity) {
+			return overviews[i].Channel < overviews[j].Channel
+		}
+
+		return overviews[i].LastActivity.After(overviews[j].LastActivity)
+	})
+
+	if asJSON {
+		return printJSON(overviews)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "channel\tmessages\tlast activity\tparticipants")
+
+	for _, overview := range overviews {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%s\t%d\n",
+			overview.Channel,
+			overview.Messages,
+			overview.LastActivity.Format(time.ANSIC),
+			overview.Participants,
+		)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/reconquest/ser-go"
+)
+
+// parsedFile is a history file, which is parsed in background; ready is
+// closed when messages are parsed.
+type parsedFile struct {
+	file     HistoryFile
+	messages []*Message
+	err      error
+	ready    chan struct{}
+}
+
+// parallelSearch parses history files in several goroutines, while parsed
+// messages are searched and printed in single goroutine in order of files,
+// so output is the same for any number of jobs. At most twice as many files
+// as there are jobs are kept in memory at once.
+type parallelSearch struct {
+	searcher *Searcher
+	slots    chan struct{}
+	pending  chan *parsedFile
+	done     chan error
+
+	mutex sync.Mutex
+	err   error
+}
+
+func newParallelSearch(searcher *Searcher, jobs int) *parallelSearch {
+	parallel := &parallelSearch{
+		searcher: searcher,
+		slots:    make(chan struct{}, jobs),
+		pending:  make(chan *parsedFile, jobs),
+		done:     make(chan error, 1),
+	}
+
+	go parallel.consume()
+
+	return parallel
+}
+
+// Search reads history file and starts parsing it in background. It can be
+// passed to walkHistory in place of Searcher.Search. Error of previously
+// searched file is returned, if there was one.
+func (parallel *parallelSearch) Search(file HistoryFile, reader io.Reader) error {
+	err := parallel.failure()
+	if err != nil {
+		return err
+	}
+
+	searcher := parallel.searcher
+	if searcher.Prefilter != nil && !searcher.Prefilter.MayContain(file) {
+		return nil
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return ser.Errorf(err, "can't read history file %q", file.Location())
+	}
+
+	parsed := &parsedFile{
+		file:  file,
+		ready: make(chan struct{}),
+	}
+
+	parallel.slots <- struct{}{}
+
+	go func() {
+		defer func() {
+			<-parallel.slots
+			close(parsed.ready)
+		}()
+
+		walk := searcher.read(file, bytes.NewReader(data))
+
+		parsed.err = walk(func(message *Message) error {
+			parsed.messages = append(parsed.messages, message)
+			return nil
+		})
+	}()
+
+	parallel.pending <- parsed
+
+	return nil
+}
+
+// Wait waits until all history files are searched and returns the first
+// error, which occurred.
+func (parallel *parallelSearch) Wait() error {
+	close(parallel.pending)
+
+	return <-parallel.done
+}
+
+// consume searches parsed files in order they were passed to Search. After
+// error remaining files are only drained.
+func (parallel *parallelSearch) consume() {
+	var err error
+
+	for parsed := range parallel.pending {
+		<-parsed.ready
+
+		if err != nil {
+			continue
+		}
+
+		err = parsed.err
+		if err == nil {
+			err = parallel.searcher.SearchMessages(parsed.file, parsed.messages)
+		}
+
+		if err != nil {
+			parallel.mutex.Lock()
+			parallel.err = err
+			parallel.mutex.Unlock()
+		}
+	}
+
+	parallel.done <- err
+}
+
+func (parallel *parallelSearch) failure() error {
+	parallel.mutex.Lock()
+	defer parallel.mutex.Unlock()
+
+	return parallel.err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParallelSearchOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	for channel := 0; channel < 12; channel++ {
+		lines := []string{}
+
+		for minute := 0; minute < 50; minute++ {
+			lines = append(lines, fmt.Sprintf(
+				"MR 20240102T10:%02d:00Z 001 <user%d> deploy %d in channel %d",
+				minute, minute%5, minute, channel,
+			), fmt.Sprintf("line of body %d", minute))
+		}
+
+		writeHistory(t, dir, fmt.Sprintf("channel%02d", channel), lines...)
+	}
+
+	search := func(jobs string) string {
+		output, err := runSearch(t,
+			"--path", dir, "--since", "100000h", "--jobs", jobs,
+			"--all-channels", "deploy",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return output
+	}
+
+	want := search("1")
+
+	for run := 0; run < 5; run++ {
+		for _, jobs := range []string{"2", "4", "8"} {
+			got := search(jobs)
+			if got != want {
+				t.Fatalf(
+					"output with --jobs %s differs from sequential one "+
+						"in run %d:\n%s",
+					jobs, run, got,
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Presence is a status change of chat participant, parsed from info message.
+type Presence struct {
+	Nick   string
+	Status string
+}
+
+// presenceStatuses maps words, used in info messages to describe status, to
+// status names, accepted by --status.
+var presenceStatuses = map[string]string{
+	"online":        "online",
+	"available":     "online",
+	"free":          "free",
+	"away":          "away",
+	"xa":            "xa",
+	"extended away": "xa",
+	"dnd":           "dnd",
+	"busy":          "dnd",
+	"invisible":     "invisible",
+	"offline":       "offline",
+	"unavailable":   "offline",
+}
+
+var (
+	presenceJoined = regexp.MustCompile(`^(\S+) has joined`)
+	presenceLeft   = regexp.MustCompile(
+		`^(\S+) has (?:left|quit|been kicked|been banned)`,
+	)
+	presenceChanged = regexp.MustCompile(
+		`^(\S+) (?:is now|changed status to|status:) ([\w ]+?)\.?(?: \(|$)`,
+	)
+)
+
+// parsePresence extracts presence change from info message. History doesn't
+// store presence distinctly, so it is guessed by message text: "<nick> has
+// joined" means online, "<nick> has left" (or quit, been kicked, been banned)
+// means offline and "<nick> is now <status>" sets specified status, if it is
+// one of known ones.
+func parsePresence(message *Message) (Presence, bool) {
+	if message.Header.Direction != DirectionInfo {
+		return Presence{}, false
+	}
+
+	text := message.Header.Message
+
+	if match := presenceJoined.FindStringSubmatch(text); match != nil {
+		return Presence{Nick: match[1], Status: "online"}, true
+	}
+
+	if match := presenceLeft.FindStringSubmatch(text); match != nil {
+		return Presence{Nick: match[1], Status: "offline"}, true
+	}
+
+	if match := presenceChanged.FindStringSubmatch(text); match != nil {
+		status, ok := presenceStatuses[strings.ToLower(match[2])]
+		if ok {
+			return Presence{Nick: match[1], Status: status}, true
+		}
+	}
+
+	return Presence{}, false
+}
+
+// colorizePresence renders info message text with color, depending on
+// presence status it describes.
+func colorizePresence(message *Message) string {
+	presence, ok := parsePresence(message)
+	if !ok {
+		return message.Header.Message
+	}
+
+	switch presence.Status {
+	case "online", "free":
+		return color.GreenString(message.Header.Message)
+
+	case "offline":
+		return color.RedString(message.Header.Message)
+
+	default:
+		return color.YellowString(message.Header.Message)
+	}
+}
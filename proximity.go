@@ -0,0 +1,106 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/reconquest/ser-go"
+)
+
+// ProximityMatcher matches texts, which contain all terms within specified
+// distance: number of other words between the first and the last term.
+type ProximityMatcher struct {
+	Terms    []*regexp.Regexp
+	Distance int
+}
+
+func newProximityMatcher(
+	terms []string,
+	distance int,
+) (*ProximityMatcher, error) {
+	matcher := &ProximityMatcher{
+		Distance: distance,
+	}
+
+	for _, term := range terms {
+		expression, err := regexp.Compile(`(?i)^(?:` + term + `)$`)
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't compile regexp %q",
+				term,
+			)
+		}
+
+		matcher.Terms = append(matcher.Terms, expression)
+	}
+
+	return matcher, nil
+}
+
+// Match reports whether text contains all terms close to each other.
+func (matcher *ProximityMatcher) Match(text string) bool {
+	if len(matcher.Terms) == 0 {
+		return true
+	}
+
+	words := splitWords(text)
+
+	type occurrence struct {
+		position int
+		term     int
+	}
+
+	occurrences := []occurrence{}
+	for position, word := range words {
+		for term, expression := range matcher.Terms {
+			if expression.MatchString(word) {
+				occurrences = append(occurrences, occurrence{position, term})
+			}
+		}
+	}
+
+	// Find the shortest window of occurrences with all terms in it using
+	// sliding window over occurrences, sorted by position.
+	var (
+		counts  = make([]int, len(matcher.Terms))
+		covered = 0
+		left    = 0
+	)
+
+	for _, right := range occurrences {
+		if counts[right.term] == 0 {
+			covered++
+		}
+
+		counts[right.term]++
+
+		for covered == len(matcher.Terms) {
+			first := occurrences[left]
+
+			span := right.position - first.position + 1
+			if span-len(matcher.Terms) <= matcher.Distance {
+				return true
+			}
+
+			counts[first.term]--
+			if counts[first.term] == 0 {
+				covered--
+			}
+
+			left++
+		}
+	}
+
+	return false
+}
+
+// splitWords splits text into words: sequences of letters, digits, dashes and
+// underscores.
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsNumber(char) &&
+			char != '_' && char != '-'
+	})
+}
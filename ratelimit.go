@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimiter is a token bucket, which allows at most Limit messages per
+// Interval and counts dropped ones.
+type RateLimiter struct {
+	Limit    int
+	Interval time.Duration
+
+	tokens  float64
+	updated time.Time
+	dropped int
+}
+
+// parseRateLimit parses rate limit in form of <n>/<duration>, like 10/1m or
+// 5/s.
+func parseRateLimit(value string) (*RateLimiter, error) {
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return nil, fmt.Errorf(
+			"can't parse rate limit %q: should be in form <n>/<duration>",
+			value,
+		)
+	}
+
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("can't parse rate limit count %q", count)
+	}
+
+	if period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		period = "1" + period
+	}
+
+	interval, err := time.ParseDuration(period)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf(
+			"can't parse rate limit interval %q",
+			period,
+		)
+	}
+
+	return &RateLimiter{
+		Limit:    limit,
+		Interval: interval,
+		tokens:   float64(limit),
+		updated:  time.Now(),
+	}, nil
+}
+
+// Allow reports whether next message can be output now. Not allowed messages
+// are counted as dropped.
+func (limiter *RateLimiter) Allow() bool {
+	now := time.Now()
+
+	limiter.tokens += float64(limiter.Limit) *
+		float64(now.Sub(limiter.updated)) / float64(limiter.Interval)
+	if limiter.tokens > float64(limiter.Limit) {
+		limiter.tokens = float64(limiter.Limit)
+	}
+
+	limiter.updated = now
+
+	if limiter.tokens < 1 {
+		limiter.dropped++
+
+		return false
+	}
+
+	limiter.tokens--
+
+	return true
+}
+
+// Dropped returns number of messages, dropped since previous call.
+func (limiter *RateLimiter) Dropped() int {
+	dropped := limiter.dropped
+	limiter.dropped = 0
+
+	return dropped
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// Scorer computes relevance score of message; higher score means more
+// relevant message.
+type Scorer func(message *Message) float64
+
+type scoredMessage struct {
+	file    HistoryFile
+	message *Message
+	score   float64
+}
+
+// combineScorers returns scorer, which sums scores of given scorers,
+// multiplied by corresponding weights.
+func combineScorers(weights []float64, scorers ...Scorer) Scorer {
+	return func(message *Message) float64 {
+		total := 0.0
+		for index, scorer := range scorers {
+			total += weights[index] * scorer(message)
+		}
+
+		return total
+	}
+}
+
+// newRelevanceScorer returns default relevance scorer, which prefers
+// messages, containing more of filter terms, with denser matches, and more
+// recent ones within search window, which ends at specified moment. Terms
+// are regexps, so literal terms should be already quoted.
+func newRelevanceScorer(
+	terms []string,
+	window time.Duration,
+	now time.Time,
+) (Scorer, error) {
+	expressions := []*regexp.Regexp{}
+	for _, term := range terms {
+		expression, err := regexp.Compile(`(?i)` + term)
+		if err != nil {
+			return nil, ser.Errorf(
+				err,
+				"can't compile regexp %q",
+				term,
+			)
+		}
+
+		expressions = append(expressions, expression)
+	}
+
+	return combineScorers(
+		[]float64{1, 0.5, 0.25},
+		scoreCoverage(expressions),
+		scoreDensity(expressions),
+		scoreRecency(window, now),
+	), nil
+}
+
+// scoreCoverage scores message by fraction of terms it contains.
+func scoreCoverage(terms []*regexp.Regexp) Scorer {
+	return func(message *Message) float64 {
+		if len(terms) == 0 {
+			return 0
+		}
+
+		text := message.Text()
+
+		found := 0
+		for _, term := range terms {
+			if term.MatchString(text) {
+				found++
+			}
+		}
+
+		return float64(found) / float64(len(terms))
+	}
+}
+
+// scoreDensity scores message by number of term occurrences per word.
+func scoreDensity(terms []*regexp.Regexp) Scorer {
+	return func(message *Message) float64 {
+		var (
+			text  = message.Text()
+			words = len(strings.Fields(text))
+		)
+
+		if words == 0 {
+			return 0
+		}
+
+		occurrences := 0
+		for _, term := range terms {
+			occurrences += len(term.FindAllStringIndex(text, -1))
+		}
+
+		density := float64(occurrences) / float64(words)
+		if density > 1 {
+			density = 1
+		}
+
+		return density
+	}
+}
+
+// scoreRecency scores message by its age: from one for just written message
+// to zero for message at the beginning of window, which ends at specified
+// moment.
+func scoreRecency(window time.Duration, now time.Time) Scorer {
+	return func(message *Message) float64 {
+		if window <= 0 {
+			return 0
+		}
+
+		age := now.Sub(message.Header.Time)
+		if age > window {
+			return 0
+		}
+
+		return 1 - float64(age)/float64(window)
+	}
+}
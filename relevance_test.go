@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRelevanceFixedStrings(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> c++( once",
+		"MR 20240102T10:01:00Z 000 <bob> c++( and c++( twice",
+		"MR 20240102T10:02:00Z 000 <carol> ccc( is not it",
+	)
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--plain",
+		"--fixed-strings", "--sort-by-relevance", "work", "c++(",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(output, "carol") {
+		t.Errorf("literal term matched as regexp, got:\n%s", output)
+	}
+
+	if strings.Index(output, "bob") > strings.Index(output, "alice") {
+		t.Errorf("denser match should be printed first, got:\n%s", output)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+)
+
+// Replayer paces output of messages according to gaps between their times,
+// scaled by Speed and capped by MaxDelay.
+type Replayer struct {
+	Speed    float64
+	MaxDelay time.Duration
+
+	previous time.Time
+}
+
+// Wait sleeps for scaled gap between time of previously output message and
+// specified time. Messages, which go before previous one, are not delayed.
+func (replayer *Replayer) Wait(moment time.Time) {
+	previous := replayer.previous
+	replayer.previous = moment
+
+	if previous.IsZero() || !moment.After(previous) {
+		return
+	}
+
+	delay := time.Duration(float64(moment.Sub(previous)) / replayer.Speed)
+	if replayer.MaxDelay > 0 && delay > replayer.MaxDelay {
+		delay = replayer.MaxDelay
+	}
+
+	time.Sleep(delay)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+)
+
+// replyLookBehind is a number of previous messages of history file, which
+// quoted text of reply is looked up in.
+const replyLookBehind = 100
+
+// replyDetector detects replies to previous messages. History format doesn't
+// keep reply markers of clients (XEP-0461), so detection is heuristic: reply
+// is a message, which text starts with quoted line, and quoted line is
+// found in one of replyLookBehind previous messages of the same file.
+// Replies to older messages, as well as replies, which quote text after
+// answer, are not detected.
+type replyDetector struct {
+	prefix string
+	recent []string
+}
+
+func newReplyDetector(prefix string) *replyDetector {
+	return &replyDetector{
+		prefix: prefix,
+	}
+}
+
+// Detect reports whether message replies to one of previous messages.
+// Messages should be passed in chronological order.
+func (detector *replyDetector) Detect(message *Message) bool {
+	var (
+		text  = strings.ToLower(message.Content())
+		reply = false
+	)
+
+	first, _, _ := strings.Cut(text, "\n")
+	if strings.HasPrefix(first, detector.prefix) {
+		quote := strings.TrimSpace(strings.TrimPrefix(first, detector.prefix))
+
+		for _, previous := range detector.recent {
+			if quote != "" && strings.Contains(previous, quote) {
+				reply = true
+				break
+			}
+		}
+	}
+
+	detector.recent = append(detector.recent, text)
+	if len(detector.recent) > replyLookBehind {
+		detector.recent = detector.recent[1:]
+	}
+
+	return reply
+}
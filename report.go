@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Report aggregates matching messages and prints summary over them instead
+// of messages themselves.
+type Report interface {
+	Add(file HistoryFile, message *Message) error
+	Print(asJSON bool) error
+}
+
+// newReport returns report, requested by command line arguments, or nil if
+// messages should be printed as is.
+func newReport(args map[string]interface{}) (Report, error) {
+	if value, ok := args["--gaps"].(string); ok {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse gaps count %q: %s",
+				value, err,
+			)
+		}
+
+		if count <= 0 {
+			return nil, fmt.Errorf("gaps count %q should be positive", value)
+		}
+
+		window, err := time.ParseDuration(args["--burst-window"].(string))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				args["--burst-window"].(string), err,
+			)
+		}
+
+		return &GapsReport{
+			Count:       count,
+			BurstWindow: window,
+		}, nil
+	}
+
+	if args["--diff"].(bool) {
+		now, err := parseAsOf(args)
+		if err != nil {
+			return nil, err
+		}
+
+		since, err := parseSince(args, now)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DiffReport{
+			Since: since,
+		}, nil
+	}
+
+	if value, ok := args["--word-freq"].(string); ok {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse words count %q: %s",
+				value, err,
+			)
+		}
+
+		if count <= 0 {
+			return nil, fmt.Errorf("words count %q should be positive", value)
+		}
+
+		path, _ := args["--stopwords"].(string)
+
+		return newWordsReport(count, path)
+	}
+
+	if args["--files-with-matches"].(bool) {
+		return &FilesReport{
+			Null: args["--output-null"].(bool),
+		}, nil
+	}
+
+	if args["--count-total"].(bool) {
+		return &TotalReport{}, nil
+	}
+
+	if value, ok := args["--bucket"].(string); ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
+			)
+		}
+
+		if interval <= 0 {
+			return nil, fmt.Errorf("bucket size %q should be positive", value)
+		}
+
+		now, err := parseAsOf(args)
+		if err != nil {
+			return nil, err
+		}
+
+		since, err := parseSince(args, now)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SeriesReport{
+			Interval: interval,
+			Since:    since,
+			Until:    now,
+		}, nil
+	}
+
+	if args["--list-nicks"].(bool) {
+		order := args["--nicks-order"].(string)
+		if order != "count" && order != "name" {
+			return nil, fmt.Errorf("unknown nicks order %q", order)
+		}
+
+		return &NicksReport{
+			ByName: order == "name",
+		}, nil
+	}
+
+	if args["--overview"].(bool) {
+		return &OverviewReport{}, nil
+	}
+
+	if args["--active-days"].(bool) {
+		return &ActiveDaysReport{}, nil
+	}
+
+	if args["--count-by-direction"].(bool) {
+		return &DirectionReport{
+			PerChannel: args["--group-by-channel"].(bool),
+		}, nil
+	}
+
+	if args["--age-buckets"].(bool) {
+		now, err := parseAsOf(args)
+		if err != nil {
+			return nil, err
+		}
+
+		return newAgeBucketsReport(args["--age-buckets-spec"].(string), now)
+	}
+
+	if args["--attachments"].(bool) {
+		return &AttachmentsReport{}, nil
+	}
+
+	if args["--urls"].(bool) {
+		return &URLsReport{}, nil
+	}
+
+	if path, ok := args["--export-html"].(string); ok {
+		return &HTMLExport{
+			Path: path,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// printJSONLine prints value as JSON on single line.
+func printJSONLine(value interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(value)
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
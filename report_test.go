@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportCount(t *testing.T) {
+	tests := []struct {
+		argv []string
+		err  string
+	}{
+		{[]string{"--gaps=-1"}, "should be positive"},
+		{[]string{"--gaps=0"}, "should be positive"},
+		{[]string{"--gaps=x"}, "can't parse gaps count"},
+		{[]string{"--gaps=3"}, ""},
+		{[]string{"--word-freq=-1"}, "should be positive"},
+		{[]string{"--word-freq=0"}, "should be positive"},
+		{[]string{"--word-freq=10"}, ""},
+	}
+
+	for _, test := range tests {
+		args := parseTestArgs(t, append(test.argv, "-S", "work")...)
+
+		_, err := newReport(args)
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %s", test.argv, err)
+
+		case test.err != "" &&
+			(err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q: got error %v, want %q", test.argv, err, test.err)
+		}
+	}
+}
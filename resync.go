@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// maxPlausibleLength is a maximum number of body lines in message, which
+	// header is considered plausible by --robust-resync.
+	maxPlausibleLength = 1000
+
+	// plausibleTimeSkew is a maximum difference between time of message,
+	// which header is considered plausible by --robust-resync, and time of
+	// previous record or current time.
+	plausibleTimeSkew = 24 * time.Hour
+)
+
+var (
+	// skipErrors makes readMessages to skip malformed lines until the next
+	// line, which parses as header, instead of failing.
+	skipErrors bool
+
+	// robustResync makes readMessages to resync after malformed lines only
+	// on header, which looks plausible, see checkPlausibleHeader.
+	robustResync bool
+)
+
+// oldestPlausibleTime is a time before any history could be written by
+// mcabber.
+var oldestPlausibleTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// checkPlausibleHeader returns error, if header, which is parsed after
+// malformed lines, is most likely body line of broken message, which just
+// looks like header: its length is too large or its time is far from time of
+// previous record.
+func checkPlausibleHeader(header *Header, previous time.Time) error {
+	if header.Length > maxPlausibleLength {
+		return fmt.Errorf("implausible length %d", header.Length)
+	}
+
+	if header.Time.Before(oldestPlausibleTime) ||
+		header.Time.After(time.Now().Add(plausibleTimeSkew)) {
+		return fmt.Errorf("implausible datetime %s", header.Time)
+	}
+
+	if !previous.IsZero() &&
+		header.Time.Before(previous.Add(-plausibleTimeSkew)) {
+		return fmt.Errorf(
+			"datetime %s is too far before previous record at %s",
+			header.Time,
+			previous,
+		)
+	}
+
+	return nil
+}
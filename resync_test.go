@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// messageTexts returns header messages of specified messages.
+func messageTexts(messages []*Message) []string {
+	texts := []string{}
+	for _, message := range messages {
+		texts = append(texts, message.Header.Message)
+	}
+
+	return texts
+}
+
+func TestBodyLookingLikeHeader(t *testing.T) {
+	content := "MR 20240102T10:00:00Z 002 <alice> paste of log:\n" +
+		"MR 20240102T10:05:00Z 000 <eve> fake\n" +
+		"MI 20240102T10:06:00Z 001 also fake\n" +
+		"MR 20240102T10:01:00Z 000 <bob> real\n"
+
+	for _, options := range [][]string{
+		nil,
+		{"--skip-errors"},
+		{"--robust-resync"},
+	} {
+		messages, err := readTestMessages(t, content, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := messageTexts(messages)
+		want := []string{"<alice> paste of log:", "<bob> real"}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("with %q got messages %q, want %q", options, got, want)
+		}
+
+		body := []string{
+			"MR 20240102T10:05:00Z 000 <eve> fake",
+			"MI 20240102T10:06:00Z 001 also fake",
+		}
+
+		if !reflect.DeepEqual(messages[0].Body, body) {
+			t.Errorf(
+				"with %q got body %q, want %q",
+				options, messages[0].Body, body,
+			)
+		}
+	}
+}
+
+func TestRobustResync(t *testing.T) {
+	content := "MR 20240102T10:00:00Z 000 <alice> hello\n" +
+		"garbage of broken message\n" +
+		"MR 19990101T00:00:00Z 005 <eve> old body line\n" +
+		"MR 20240102T10:00:00Z 5000 <eve> long body line\n" +
+		"MR 20240102T10:02:00Z 000 <bob> real\n"
+
+	tests := []struct {
+		options []string
+		want    []string
+	}{
+		{
+			[]string{"--skip-errors"},
+			[]string{"<alice> hello"},
+		},
+		{
+			[]string{"--robust-resync"},
+			[]string{"<alice> hello", "<bob> real"},
+		},
+	}
+
+	for _, test := range tests {
+		messages, err := readTestMessages(t, content, test.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := messageTexts(messages)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"with %q got messages %q, want %q",
+				test.options, got, test.want,
+			)
+		}
+	}
+}
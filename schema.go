@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// printJSONSchema prints JSON Schema of messages in --json output. Schema is
+// generated from JSONMessage, so it is always in sync with actual output.
+func printJSONSchema() error {
+	var (
+		kind       = reflect.TypeOf(JSONMessage{})
+		properties = map[string]interface{}{}
+		required   = []string{}
+	)
+
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = jsonSchemaType(field.Type)
+
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return printJSON(map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "mcabber-history message",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	})
+}
+
+// jsonSchemaType returns JSON Schema of value of specified Go type.
+func jsonSchemaType(kind reflect.Type) map[string]interface{} {
+	switch kind {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{
+			"type":   "string",
+			"format": "date-time",
+		}
+
+	case reflect.TypeOf(Direction("")):
+		return map[string]interface{}{
+			"type": "string",
+			"enum": []Direction{
+				DirectionSend,
+				DirectionRecv,
+				DirectionInfo,
+			},
+		}
+	}
+
+	switch kind.Kind() {
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": jsonSchemaType(kind.Elem()),
+		}
+
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
@@ -0,0 +1,1560 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/reconquest/ser-go"
+)
+
+// Message is a single history record: header line and body lines, which
+// follow it.
+type Message struct {
+	Header *Header
+	Body   []string
+
+	// Offset is a position of message header line in file and Size is
+	// a number of bytes message takes there, including body lines.
+	Offset int64
+	Size   int64
+
+	// Correction is set if message is detected as correction of previous
+	// message of the same sender.
+	Correction bool
+
+	// Reply is set if message is detected as reply to previous message; it
+	// is detected only if requested.
+	Reply bool
+
+	// Code is a reason, why message is detected as pasted code, see
+	// CodeDetector; it is empty if detection is not requested.
+	Code string
+
+	// CrossPosted lists other channels, where same message was posted.
+	CrossPosted []string
+
+	// PreviousTime is a time of previous record in history file, including
+	// skipped ones; it is zero for the first record.
+	PreviousTime time.Time
+
+	// Context is set for message, which doesn't match itself, but is
+	// printed as context of matching one. ContextBreak is set for the first
+	// message of context block, which is not adjacent to previous block.
+	Context      bool
+	ContextBreak bool
+
+	// Mentioned lists nicks, requested by --mentions, which message
+	// mentions.
+	Mentioned []string
+
+	// URLs lists URLs of message, which belong to domain, requested by
+	// --url-domain.
+	URLs []string
+
+	// ID is an identifier of message, see messageID. It is computed from
+	// parsed message before any changes of its text, like quote folding or
+	// escaping, so it doesn't depend on display options.
+	ID string
+
+	// DecryptedID is set to ID of encrypted message, which text is replaced
+	// by plaintext from decrypt map.
+	DecryptedID string
+
+	// Explanation describes which filter terms message matched, see
+	// MatchExplainer.
+	Explanation string
+}
+
+// Searcher filters messages from history files and prints matching ones.
+type Searcher struct {
+	Filter *regexp.Regexp
+
+	// ExactMatch makes searcher to match filter, which is anchored in that
+	// case, against message content only, see Message.Content.
+	ExactMatch bool
+
+	// Since is a time of the oldest message to match.
+	Since time.Time
+
+	// ReadMarkers, if set, overrides Since for channels, which have marker
+	// of the last read message.
+	ReadMarkers ReadMarkers
+
+	// Until, if not zero, is a time of the newest message to match.
+	Until time.Time
+
+	// AfterSilence, if not zero, limits matching messages to ones, which
+	// were written at least that time after previous message in the same
+	// file. The first message in file is always considered to be such one.
+	AfterSilence time.Duration
+
+	// Weekdays, if not nil, limits matching messages to ones, written on
+	// specified weekdays.
+	Weekdays map[time.Weekday]bool
+
+	// BeforeTime and AfterTime specify time window around matching message;
+	// messages from the same file, written in that window, will be printed
+	// as context.
+	BeforeTime time.Duration
+	AfterTime  time.Duration
+
+	// QuotePrefix, if not empty, enables folding of consecutive quoted body
+	// lines, which start with that prefix, when printing messages. Matching
+	// is still done against full message.
+	QuotePrefix string
+
+	// StripFormatting enables removing of terminal and IRC formatting
+	// sequences from messages before matching and printing them. If Raw is
+	// set, messages are printed as is, but still matched without formatting.
+	StripFormatting bool
+	Raw             bool
+
+	// BinarySafe enables escaping of control characters and invalid UTF-8
+	// bytes in messages before matching and printing them, see
+	// escapeControls. Raw makes searcher to print messages as is.
+	BinarySafe bool
+
+	// NormalizeWhitespace enables collapsing of whitespace runs in messages
+	// before matching them. Messages are printed normalized only if
+	// NormalizeDisplay is set.
+	NormalizeWhitespace bool
+	NormalizeDisplay    bool
+
+	// Decrypt, if not nil, replaces text of encrypted messages by their
+	// plaintext before matching and printing them.
+	Decrypt DecryptMap
+
+	// Explain, if not nil, annotates printed matching messages with terms,
+	// which matched them.
+	Explain *MatchExplainer
+
+	// Prefilter, if not nil, skips history files, which don't contain
+	// literal filter terms, without reading them.
+	Prefilter *BloomPrefilter
+
+	// Proximity, if not nil, additionally requires filter terms to be close
+	// to each other in message.
+	Proximity *ProximityMatcher
+
+	// LinesMin and LinesMax, if not zero, limit number of lines in matching
+	// messages, including header line.
+	LinesMin int
+	LinesMax int
+
+	// IncludeInfo makes searcher to match info messages too, which are
+	// skipped otherwise. If Status is not empty, only info messages, which
+	// describe presence change to that status, are matched.
+	IncludeInfo bool
+	Status      string
+
+	// MergeInfo makes searcher to print info messages, written within
+	// InfoWindow around matching messages, as their context, even if info
+	// messages are not matched.
+	MergeInfo  bool
+	InfoWindow time.Duration
+
+	// IgnoreOwn makes searcher to skip messages, sent by user, including
+	// received multi-user chat messages from OwnNick, if it is not empty.
+	IgnoreOwn bool
+	OwnNick   string
+
+	// Mention, if not nil, detects messages, which mention user nick; they
+	// are marked in output. MentionsOnly makes searcher to output only such
+	// messages.
+	Mention      *MentionMatcher
+	MentionsOnly bool
+
+	// Mentions, if not empty, limits matching messages to ones, which
+	// mention all of nicks or any of them, if MentionsAny is set.
+	Mentions    []*MentionMatcher
+	MentionsAny bool
+
+	// URLDomain, if not nil, limits matching messages to ones, which contain
+	// URLs of specified domain.
+	URLDomain *URLDomainMatcher
+
+	// CorrectionsOnly makes searcher to output only messages, detected as
+	// corrections of previous ones.
+	CorrectionsOnly bool
+
+	// RepliesOnly makes searcher to output only messages, detected as
+	// replies, which start with line quoted by ReplyQuotePrefix.
+	RepliesOnly      bool
+	ReplyQuotePrefix string
+
+	// Code, if not nil, limits matching messages to ones, which look like
+	// pasted code.
+	Code *CodeDetector
+
+	// LineRegexp makes searcher to match filter against every message line
+	// separately instead of whole message. Matching body lines are
+	// highlighted; if MatchingLinesOnly is set, other body lines are not
+	// printed.
+	LineRegexp        bool
+	MatchingLinesOnly bool
+
+	// JIDs, if not nil, is used to print names instead of sender JIDs.
+	JIDs JIDMap
+
+	// Template, if not nil, is used to render matching messages instead of
+	// default format.
+	Template *template.Template
+
+	// WithID makes searcher to prefix every printed message with its ID.
+	WithID bool
+
+	// JSON makes searcher to print messages as JSON objects, one per line.
+	JSON bool
+
+	// Markdown makes searcher to print messages as Markdown blockquotes.
+	Markdown bool
+
+	// ShowOffsets makes searcher to prefix printed messages with file name,
+	// offset and size of message in file.
+	ShowOffsets bool
+
+	// Plain makes searcher to print messages without direction arrows and
+	// colors, only with time and text.
+	Plain bool
+
+	// Anonymizer, if not nil, replaces identities in output messages with
+	// pseudonyms.
+	Anonymizer *Anonymizer
+
+	// Highlight, if not nil, highlights filter terms in printed messages.
+	Highlight *Highlighter
+
+	// ISOTimestamps makes searcher to print time of messages in RFC 3339
+	// format with zone offset instead of ANSI C format.
+	ISOTimestamps bool
+
+	// Exec, if not nil, receives matching messages instead of printing them.
+	Exec *Executor
+
+	// Collect, if not nil, receives matching messages instead of printing
+	// them; it's used to build reports over matching messages.
+	Collect func(file HistoryFile, message *Message) error
+
+	// Limit specifies maximum number of messages to output; zero means no
+	// limit.
+	Limit int
+
+	// Relevance, if not nil, makes searcher to buffer all matching messages
+	// and output them ordered by descending score.
+	Relevance Scorer
+
+	// Merge makes searcher to buffer all matching messages and output them
+	// in chronological order, prefixed with channel name.
+	Merge bool
+
+	// Chronological is same as Merge, but messages are not prefixed with
+	// channel name; useful for single channel split into rotated files.
+	Chronological bool
+
+	// Unanswered, if not nil, limits matching messages to sent questions,
+	// which got no reply.
+	Unanswered *UnansweredDetector
+
+	// ContextGate, if not nil, limits matching messages to ones, which
+	// surrounding messages match its pattern.
+	ContextGate *ContextGate
+
+	// SortByNick makes searcher to buffer all matching messages and output
+	// them grouped by sender under header with nick. Groups are ordered by
+	// number of messages or by nick, if NicksByName is set.
+	SortByNick  bool
+	NicksByName bool
+
+	// DedupCrossChannel makes merging searcher to output only first of
+	// messages with the same text, posted in different channels at nearly
+	// the same time, noting other channels.
+	DedupCrossChannel bool
+
+	// GroupByChannel makes searcher to print messages under header with
+	// channel name. If CountInHeader is set, header also contains number of
+	// matching messages in channel.
+	GroupByChannel bool
+	CountInHeader  bool
+
+	// Fair makes searcher to distribute Limit between channels round-robin,
+	// so chatty channel doesn't take whole output.
+	Fair bool
+
+	// PreserveOrder makes searcher to output Limit earliest matching
+	// messages in chronological order, instead of first found ones.
+	PreserveOrder bool
+
+	// Flatten makes searcher to print consecutive messages, received from
+	// the same sender within FlattenGap, as single message.
+	Flatten    bool
+	FlattenGap time.Duration
+
+	// MergeAdjacentInfo, if not nil, collapses runs of info messages in
+	// printed output.
+	MergeAdjacentInfo *InfoMerger
+
+	// GapMarkers, if not nil, makes searcher to print marker lines between
+	// printed messages, written far apart.
+	GapMarkers *GapMarkers
+
+	// Replay, if not nil, delays output of every message according to gap
+	// between it and previous one.
+	Replay *Replayer
+
+	// RateLimit, if not nil, limits rate of output messages; messages over
+	// limit are dropped with note.
+	RateLimit *RateLimiter
+
+	// Width, if not zero, is a width to wrap printed lines at.
+	Width int
+
+	// ContextSeparator is printed instead of Separator between context
+	// blocks, which are not adjacent; NoContextSeparator disables it.
+	ContextSeparator   string
+	NoContextSeparator bool
+
+	// Separator is printed between messages; empty line is printed if it
+	// is empty. NoSeparator disables separators completely.
+	Separator   string
+	NoSeparator bool
+
+	// OutputNull makes searcher to terminate every printed message with NUL
+	// byte instead of newline and to print no separators between messages.
+	OutputNull bool
+
+	separator bool
+	previous  scoredMessage
+	fair      *fairBuffer
+	earliest  *earliestBuffer
+	channel   string
+	grouped   []scoredMessage
+	emitted   int
+	scored    []scoredMessage
+	infoRun   []scoredMessage
+}
+
+// errStop is returned by message handlers to stop reading messages.
+var errStop = errors.New("stop")
+
+// Search reads records of given history file from reader and prints messages,
+// matching searcher criteria.
+func (searcher *Searcher) Search(file HistoryFile, reader io.Reader) error {
+	if searcher.Prefilter != nil && !searcher.Prefilter.MayContain(file) {
+		return nil
+	}
+
+	return searcher.search(file, searcher.read(file, reader), searcher.emit)
+}
+
+// read returns function, which walks messages of history file, which can
+// match searcher criteria.
+func (searcher *Searcher) read(
+	file HistoryFile,
+	reader io.Reader,
+) func(handler func(*Message) error) error {
+	parse := readHeaderFilteredMessages
+	if file.Format != "" {
+		parse = parsers[file.Format]
+	}
+
+	return func(handler func(*Message) error) error {
+		return parse(
+			file.Location(),
+			reader,
+			searcher.acceptHeader,
+			handler,
+		)
+	}
+}
+
+// SearchMessages is same as Search, but searches already parsed messages of
+// given history file.
+func (searcher *Searcher) SearchMessages(
+	file HistoryFile,
+	messages []*Message,
+) error {
+	return searcher.search(
+		file,
+		func(handler func(*Message) error) error {
+			for _, message := range messages {
+				err := handler(message)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		searcher.emit,
+	)
+}
+
+// search walks messages of history file and passes matching ones along with
+// their context to emit function.
+func (searcher *Searcher) search(
+	file HistoryFile,
+	walk func(handler func(*Message) error) error,
+	emit func(file HistoryFile, message *Message) error,
+) error {
+	if searcher.isLimitReached() {
+		return nil
+	}
+
+	var (
+		withContext = searcher.BeforeTime > 0 || searcher.AfterTime > 0 ||
+			searcher.MergeInfo
+
+		// Unanswered questions are found only when replies are read, as
+		// well as context, required by ContextGate, so all messages of file
+		// are buffered.
+		buffered = withContext || searcher.Unanswered != nil ||
+			searcher.ContextGate != nil
+
+		messages = []*Message{}
+		matches  = []bool{}
+
+		since = searcher.ReadMarkers.Since(file, searcher.Since)
+
+		corrections = newCorrectionDetector()
+		replies     *replyDetector
+	)
+
+	if searcher.RepliesOnly {
+		replies = newReplyDetector(searcher.ReplyQuotePrefix)
+	}
+
+	err := walk(func(message *Message) error {
+		if !searcher.acceptHeader(message.Header) {
+			return nil
+		}
+
+		// Messages can be shared between searches, like cached ones in
+		// interactive mode, so only copy of message is annotated.
+		copied := *message
+		message = &copied
+
+		if message.ID == "" {
+			message.ID = messageID(file, message)
+		}
+
+		if searcher.Decrypt != nil {
+			message = searcher.Decrypt.Decrypt(file, message)
+		}
+
+		display := message
+
+		if searcher.StripFormatting {
+			message = stripMessageFormatting(message)
+
+			if !searcher.Raw {
+				display = message
+			}
+		}
+
+		if searcher.BinarySafe {
+			message = escapeMessageControls(message)
+
+			if !searcher.Raw {
+				display = message
+			}
+		}
+
+		if searcher.NormalizeWhitespace {
+			message = normalizeMessageWhitespace(message)
+
+			if searcher.NormalizeDisplay {
+				display = message
+			}
+		}
+
+		message.Correction = corrections.Detect(message)
+		display.Correction = message.Correction
+
+		if replies != nil {
+			message.Reply = replies.Detect(message)
+			display.Reply = message.Reply
+		}
+
+		if message.Header.Time.Before(since) {
+			return nil
+		}
+
+		var matched bool
+
+		if searcher.LineRegexp {
+			matched, display = searcher.matchLines(message, display)
+		} else if searcher.ExactMatch {
+			matched = searcher.Filter.MatchString(message.Content())
+		} else {
+			matched = searcher.Filter.MatchString(formatMessage(message))
+		}
+
+		if matched && searcher.Proximity != nil {
+			matched = searcher.Proximity.Match(message.Text())
+		}
+
+		if searcher.CorrectionsOnly && !message.Correction {
+			matched = false
+		}
+
+		if searcher.RepliesOnly && !message.Reply {
+			matched = false
+		}
+
+		if searcher.Code != nil {
+			message.Code = searcher.Code.Detect(message)
+			display.Code = message.Code
+
+			if message.Code == "" {
+				matched = false
+			}
+		}
+
+		if searcher.MentionsOnly && !searcher.Mention.Match(message) {
+			matched = false
+		}
+
+		if len(searcher.Mentions) > 0 {
+			message.Mentioned = searcher.mentioned(message)
+			display.Mentioned = message.Mentioned
+
+			if len(message.Mentioned) == 0 ||
+				!searcher.MentionsAny &&
+					len(message.Mentioned) < len(searcher.Mentions) {
+				matched = false
+			}
+		}
+
+		if searcher.URLDomain != nil {
+			message.URLs = searcher.URLDomain.Match(message)
+			display.URLs = message.URLs
+
+			if len(message.URLs) == 0 {
+				matched = false
+			}
+		}
+
+		if searcher.AfterSilence > 0 && !message.PreviousTime.IsZero() &&
+			message.Header.Time.Sub(message.PreviousTime) <
+				searcher.AfterSilence {
+			matched = false
+		}
+
+		// Info messages are accepted by MergeInfo only to be printed as
+		// context.
+		if message.Header.Direction == DirectionInfo && !searcher.IncludeInfo {
+			matched = false
+		}
+
+		if matched && searcher.Status != "" {
+			presence, ok := parsePresence(message)
+			matched = ok && presence.Status == searcher.Status
+		}
+
+		if matched {
+			stats.Matched++
+		}
+
+		// Offsets are counted in message, as it is printed without colors.
+		if matched && searcher.Explain != nil {
+			subject := stripFormatting(formatMessage(message))
+			if searcher.ExactMatch {
+				subject = message.Content()
+			}
+
+			display.Explanation = searcher.Explain.Explain(subject)
+		}
+
+		if buffered {
+			messages = append(messages, display)
+			matches = append(matches, matched)
+
+			return nil
+		}
+
+		if matched {
+			return emit(file, display)
+		}
+
+		return nil
+	})
+	if err == nil && searcher.Unanswered != nil {
+		searcher.Unanswered.Filter(messages, matches)
+	}
+
+	if err == nil && searcher.ContextGate != nil {
+		searcher.ContextGate.Filter(messages, matches)
+	}
+
+	if err == nil && withContext {
+		err = searcher.printWithContext(file, messages, matches, emit)
+	} else if err == nil && buffered {
+		for index, message := range messages {
+			if !matches[index] {
+				continue
+			}
+
+			err = emit(file, message)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	if err == errStop {
+		return nil
+	}
+
+	return err
+}
+
+// mentioned returns nicks of Mentions, which message mentions.
+func (searcher *Searcher) mentioned(message *Message) []string {
+	nicks := []string{}
+	for _, mention := range searcher.Mentions {
+		if mention.Mentions(message) {
+			nicks = append(nicks, mention.Nick)
+		}
+	}
+
+	return nicks
+}
+
+// matchLines matches filter against every line of message and returns
+// message for display with matching body lines highlighted or with only
+// matching body lines kept.
+func (searcher *Searcher) matchLines(
+	message *Message,
+	display *Message,
+) (bool, *Message) {
+	matched := searcher.Filter.MatchString(message.Header.Message)
+
+	highlighted := *display
+	highlighted.Body = []string{}
+
+	for index, line := range message.Body {
+		if !searcher.Filter.MatchString(line) {
+			if !searcher.MatchingLinesOnly {
+				highlighted.Body = append(highlighted.Body, display.Body[index])
+			}
+
+			continue
+		}
+
+		matched = true
+
+		// JSON text should be kept as is, without escape sequences.
+		if searcher.JSON {
+			highlighted.Body = append(highlighted.Body, display.Body[index])
+			continue
+		}
+
+		highlighted.Body = append(
+			highlighted.Body,
+			color.New(color.Bold).Sprint(display.Body[index]),
+		)
+	}
+
+	return matched, &highlighted
+}
+
+// acceptHeader reports whether message with specified header can match,
+// judging only by header, so body of rejected message can be skipped.
+func (searcher *Searcher) acceptHeader(header *Header) bool {
+	if header.Direction == DirectionInfo && !searcher.IncludeInfo &&
+		!searcher.MergeInfo {
+		return false
+	}
+
+	if searcher.IgnoreOwn && searcher.isOwn(header) {
+		return false
+	}
+
+	if !searcher.Until.IsZero() && header.Time.After(searcher.Until) {
+		return false
+	}
+
+	if searcher.Weekdays != nil && !searcher.Weekdays[header.Time.Weekday()] {
+		return false
+	}
+
+	lines := header.Length + 1
+
+	if searcher.LinesMin > 0 && lines < searcher.LinesMin {
+		return false
+	}
+
+	if searcher.LinesMax > 0 && lines > searcher.LinesMax {
+		return false
+	}
+
+	return true
+}
+
+// isOwn reports whether message with specified header is sent by user: it's
+// either sent message or received multi-user chat message from OwnNick.
+func (searcher *Searcher) isOwn(header *Header) bool {
+	if header.Direction == DirectionSend {
+		return true
+	}
+
+	if header.Direction != DirectionRecv || searcher.OwnNick == "" {
+		return false
+	}
+
+	nick := extractNick(&Message{Header: header})
+
+	return strings.EqualFold(nick, searcher.OwnNick)
+}
+
+// Flush finishes search, processing all messages, which are pending after
+// all files are searched.
+func (searcher *Searcher) Flush() error {
+	if searcher.SortByNick {
+		err := searcher.flushByNick()
+		if err != nil {
+			return err
+		}
+	}
+
+	if searcher.Relevance != nil || searcher.Merge || searcher.Chronological {
+		if searcher.Relevance != nil {
+			sort.SliceStable(searcher.scored, func(i, j int) bool {
+				return searcher.scored[i].score > searcher.scored[j].score
+			})
+		} else {
+			sort.SliceStable(searcher.scored, func(i, j int) bool {
+				return searcher.scored[i].message.Header.Time.Before(
+					searcher.scored[j].message.Header.Time,
+				)
+			})
+
+			if searcher.DedupCrossChannel {
+				searcher.scored = dedupCrossChannel(searcher.scored)
+			}
+		}
+
+		scored := searcher.scored
+		searcher.scored = nil
+
+		for _, scored := range scored {
+			err := searcher.output(scored.file, scored.message)
+			if err == errStop {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if searcher.fair != nil {
+		err := searcher.fair.Flush(searcher.output)
+		if err != nil && err != errStop {
+			return err
+		}
+	}
+
+	if searcher.earliest != nil {
+		err := searcher.earliest.Flush(searcher.output)
+		if err != nil && err != errStop {
+			return err
+		}
+	}
+
+	searcher.flushGroup()
+	searcher.flushInfo()
+
+	if searcher.Exec != nil {
+		return searcher.Exec.Flush()
+	}
+
+	return nil
+}
+
+// flushByNick outputs buffered messages grouped by sender. Group headers are
+// printed only in text output.
+func (searcher *Searcher) flushByNick() error {
+	groups := groupByNick(searcher.scored, searcher.NicksByName)
+
+	searcher.scored = nil
+
+	text := searcher.Collect == nil && searcher.Exec == nil &&
+		!searcher.JSON && searcher.Template == nil && !searcher.Markdown
+
+	for _, group := range groups {
+		if searcher.isLimitReached() {
+			break
+		}
+
+		if text {
+			nick := group.nick
+			if nick == "" {
+				nick = "(info)"
+			}
+
+			searcher.printGroupHeader(
+				nick,
+				fmt.Sprintf(" (%d messages)", len(group.messages)),
+			)
+		}
+
+		for _, grouped := range group.messages {
+			err := searcher.output(grouped.file, grouped.message)
+			if err == errStop {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// printWithContext prints every buffered message, which falls into time
+// window around any of matching messages.
+func (searcher *Searcher) printWithContext(
+	file HistoryFile,
+	messages []*Message,
+	matches []bool,
+	emit func(file HistoryFile, message *Message) error,
+) error {
+	selected := make([]bool, len(messages))
+
+	for index, matched := range matches {
+		if !matched {
+			continue
+		}
+
+		var (
+			moment = messages[index].Header.Time
+			before = searcher.BeforeTime
+			after  = searcher.AfterTime
+		)
+
+		if searcher.MergeInfo {
+			before = max(before, searcher.InfoWindow)
+			after = max(after, searcher.InfoWindow)
+		}
+
+		selected[index] = true
+
+		for i := index - 1; i >= 0; i-- {
+			offset := moment.Sub(messages[i].Header.Time)
+			if offset > before {
+				break
+			}
+
+			if searcher.isContext(messages[i], offset, searcher.BeforeTime) {
+				selected[i] = true
+			}
+		}
+
+		for i := index + 1; i < len(messages); i++ {
+			offset := messages[i].Header.Time.Sub(moment)
+			if offset > after {
+				break
+			}
+
+			if searcher.isContext(messages[i], offset, searcher.AfterTime) {
+				selected[i] = true
+			}
+		}
+	}
+
+	printed := false
+
+	for index, message := range messages {
+		if !selected[index] {
+			continue
+		}
+
+		message.Context = !matches[index]
+		message.ContextBreak = printed && !selected[index-1]
+
+		printed = true
+
+		err := emit(file, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isContext reports whether message, written at specified time offset from
+// matching one, should be printed as its context: it should be within context
+// window or, for info message, within InfoWindow, if MergeInfo is set.
+func (searcher *Searcher) isContext(
+	message *Message,
+	offset time.Duration,
+	window time.Duration,
+) bool {
+	if searcher.MergeInfo && message.Header.Direction == DirectionInfo {
+		window = max(window, searcher.InfoWindow)
+	}
+
+	return offset <= window
+}
+
+func (searcher *Searcher) emit(file HistoryFile, message *Message) error {
+	if searcher.SortByNick {
+		searcher.scored = append(searcher.scored, scoredMessage{
+			file:    file,
+			message: message,
+		})
+
+		return nil
+	}
+
+	if searcher.Relevance != nil {
+		searcher.scored = append(searcher.scored, scoredMessage{
+			file:    file,
+			message: message,
+			score:   searcher.Relevance(message),
+		})
+
+		return nil
+	}
+
+	if searcher.Merge || searcher.Chronological {
+		searcher.scored = append(searcher.scored, scoredMessage{
+			file:    file,
+			message: message,
+		})
+
+		return nil
+	}
+
+	if searcher.Fair && searcher.Limit > 0 {
+		if searcher.fair == nil {
+			searcher.fair = newFairBuffer(searcher.Limit)
+		}
+
+		searcher.fair.Add(file, message)
+
+		return nil
+	}
+
+	if searcher.PreserveOrder && searcher.Limit > 0 {
+		if searcher.earliest == nil {
+			searcher.earliest = newEarliestBuffer(searcher.Limit)
+		}
+
+		searcher.earliest.Add(file, message)
+
+		return nil
+	}
+
+	return searcher.output(file, message)
+}
+
+func (searcher *Searcher) isLimitReached() bool {
+	return searcher.Limit > 0 && searcher.emitted >= searcher.Limit
+}
+
+// output passes message to command or prints it, obeying to limit.
+func (searcher *Searcher) output(file HistoryFile, message *Message) error {
+	if searcher.isLimitReached() {
+		return errStop
+	}
+
+	if searcher.RateLimit != nil {
+		if !searcher.RateLimit.Allow() {
+			return nil
+		}
+
+		if dropped := searcher.RateLimit.Dropped(); dropped > 0 {
+			searcher.printDropped(dropped)
+		}
+	}
+
+	searcher.emitted++
+
+	if searcher.Replay != nil {
+		searcher.Replay.Wait(message.Header.Time)
+	}
+
+	if searcher.Anonymizer != nil {
+		file, message = searcher.Anonymizer.Anonymize(file, message)
+	}
+
+	if searcher.Collect != nil {
+		return searcher.Collect(file, message)
+	}
+
+	if searcher.Exec != nil {
+		return searcher.Exec.Run(file, message)
+	}
+
+	if searcher.JSON {
+		record := newJSONMessage(file, message)
+		if searcher.JIDs != nil {
+			record.Nick = searcher.JIDs.Name(record.Nick)
+		}
+
+		return printJSONLine(record)
+	}
+
+	if searcher.Template != nil {
+		return searcher.printTemplate(file, message)
+	}
+
+	if searcher.Markdown {
+		searcher.printMarkdown(file, message)
+
+		return nil
+	}
+
+	if searcher.GroupByChannel {
+		searcher.group(file, message)
+
+		return nil
+	}
+
+	searcher.print(file, message)
+
+	return nil
+}
+
+// printDropped notes number of messages, dropped by rate limit. Note is
+// printed as message in text output and logged otherwise.
+func (searcher *Searcher) printDropped(dropped int) {
+	if searcher.Collect != nil || searcher.Exec != nil || searcher.JSON ||
+		searcher.Template != nil || searcher.Markdown {
+		slog.Warn("messages dropped by --rate-limit", "count", dropped)
+
+		return
+	}
+
+	searcher.printSeparator()
+
+	searcher.printRecord(color.YellowString("(%d more)", dropped))
+
+	searcher.separator = true
+}
+
+// group prints message under header of its channel. If CountInHeader is set,
+// messages of channel are buffered until messages of next channel come,
+// so only messages of one channel are kept in memory.
+func (searcher *Searcher) group(file HistoryFile, message *Message) {
+	channel := file.Channel()
+	if file.Label != "" {
+		channel = file.Label + "/" + channel
+	}
+
+	if channel != searcher.channel {
+		searcher.flushGroup()
+
+		searcher.channel = channel
+
+		if !searcher.CountInHeader {
+			searcher.printGroupHeader(channel, "")
+		}
+	}
+
+	if searcher.CountInHeader {
+		searcher.grouped = append(
+			searcher.grouped,
+			scoredMessage{file: file, message: message},
+		)
+
+		return
+	}
+
+	searcher.print(file, message)
+}
+
+// flushGroup prints buffered messages of current channel under header with
+// their count.
+func (searcher *Searcher) flushGroup() {
+	if len(searcher.grouped) == 0 {
+		return
+	}
+
+	searcher.printGroupHeader(
+		searcher.channel,
+		fmt.Sprintf(" (%d matches)", len(searcher.grouped)),
+	)
+
+	for _, grouped := range searcher.grouped {
+		searcher.print(grouped.file, grouped.message)
+	}
+
+	searcher.grouped = nil
+}
+
+// printSeparator prints separator, if something was printed before it.
+func (searcher *Searcher) printSeparator() {
+	if searcher.separator && !searcher.NoSeparator && !searcher.OutputNull {
+		fmt.Fprintln(stdout, searcher.Separator)
+	}
+}
+
+// printRecord prints text, terminated by newline or by NUL byte, if
+// OutputNull is set.
+func (searcher *Searcher) printRecord(text string) {
+	if searcher.OutputNull {
+		fmt.Fprint(stdout, text, "\x00")
+	} else {
+		fmt.Fprintln(stdout, text)
+	}
+}
+
+func (searcher *Searcher) printGroupHeader(channel string, suffix string) {
+	searcher.flushInfo()
+	searcher.printSeparator()
+
+	searcher.printRecord(
+		color.New(color.Bold).Sprintf("=== %s%s ===", channel, suffix),
+	)
+
+	searcher.separator = false
+}
+
+func (searcher *Searcher) printMessage(file HistoryFile, message *Message) {
+	if searcher.QuotePrefix != "" {
+		folded := *message
+		folded.Body = foldQuotes(message.Body, searcher.QuotePrefix)
+
+		message = &folded
+	}
+
+	if searcher.JIDs != nil {
+		message = searcher.JIDs.Resolve(message)
+	}
+
+	if searcher.isContinuation(file, message) {
+		searcher.printContinuation(file, message)
+
+		return
+	}
+
+	if message.ContextBreak && !searcher.NoContextSeparator &&
+		!searcher.OutputNull {
+		fmt.Fprintln(stdout, searcher.ContextSeparator)
+	} else {
+		searcher.printSeparator()
+	}
+
+	searcher.printGapMarker(file, message)
+
+	// Mentions and ID are detected in message without highlighting.
+	var (
+		plain  = message
+		hidden int
+	)
+
+	if searcher.Highlight != nil && !message.Context {
+		message, hidden = searcher.Highlight.Highlight(message)
+	}
+
+	text := formatMessage(message)
+	if searcher.ISOTimestamps {
+		text = formatMessageWithTime(message, time.RFC3339)
+	}
+
+	if searcher.Plain {
+		text = formatPlainMessage(message)
+	}
+
+	if message.Correction {
+		text = color.MagentaString("[corrected]") + " " + text
+	}
+
+	if message.DecryptedID != "" {
+		text = color.MagentaString("[decrypted]") + " " + text
+	}
+
+	if searcher.Mention != nil && searcher.Mention.Match(plain) {
+		text = color.RedString("[mention]") + " " + text
+	}
+
+	if searcher.Merge {
+		text = color.YellowString("["+file.Channel()+"]") + " " + text
+	}
+
+	if len(message.CrossPosted) > 0 {
+		text += " " + color.CyanString(
+			"(also in: %s)", strings.Join(message.CrossPosted, ", "),
+		)
+	}
+
+	if searcher.WithID {
+		text = color.CyanString(messageID(file, message)) + " " + text
+	}
+
+	if searcher.ShowOffsets {
+		text = color.CyanString(
+			"%s:%d:%d", file.Name, message.Offset, message.Size,
+		) + " " + text
+	}
+
+	if file.Label != "" {
+		text = color.YellowString("["+file.Label+"]") + " " + text
+	}
+
+	// Info messages, merged into context, keep their own color, so they
+	// are distinct from context messages.
+	if message.Context && !(searcher.MergeInfo &&
+		message.Header.Direction == DirectionInfo) {
+		text = color.New(color.Faint).Sprint(stripFormatting(text))
+	}
+
+	if hidden > 0 {
+		text += " " + color.New(color.Faint).Sprintf("(+%d more)", hidden)
+	}
+
+	if searcher.Width > 0 {
+		text = wrapText(text, searcher.Width)
+	}
+
+	if message.Explanation != "" {
+		text += "\n" + color.New(color.Faint).Sprint(
+			"  # matched: "+message.Explanation,
+		)
+	}
+
+	searcher.printRecord(text)
+
+	searcher.separator = true
+	searcher.previous = scoredMessage{file: file, message: message}
+}
+
+// printGapMarker prints marker line, if message is written long after
+// previously printed message of the same channel.
+func (searcher *Searcher) printGapMarker(file HistoryFile, message *Message) {
+	previous := searcher.previous
+	if searcher.GapMarkers == nil || previous.message == nil ||
+		file.Label != previous.file.Label ||
+		file.Channel() != previous.file.Channel() {
+		return
+	}
+
+	marker := searcher.GapMarkers.Marker(
+		message.Header.Time.Sub(previous.message.Header.Time),
+	)
+	if marker != "" {
+		searcher.printRecord(color.New(color.Faint).Sprint(marker))
+	}
+}
+
+// isContinuation reports whether message should be printed as continuation
+// of previously printed one with --flatten: both are received from the same
+// sender in the same channel within FlattenGap.
+func (searcher *Searcher) isContinuation(
+	file HistoryFile,
+	message *Message,
+) bool {
+	previous := searcher.previous
+	if !searcher.Flatten || previous.message == nil || message.ContextBreak {
+		return false
+	}
+
+	switch {
+	case message.Header.Direction != DirectionRecv,
+		previous.message.Header.Direction != DirectionRecv,
+		file.Label != previous.file.Label,
+		file.Channel() != previous.file.Channel(),
+		extractNick(message) != extractNick(previous.message):
+		return false
+	}
+
+	gap := message.Header.Time.Sub(previous.message.Header.Time)
+
+	return gap >= 0 && gap <= searcher.FlattenGap
+}
+
+// printContinuation prints message text without header, so it looks like
+// part of previous message.
+func (searcher *Searcher) printContinuation(
+	file HistoryFile,
+	message *Message,
+) {
+	text := message.Header.Message
+	if nick := extractNick(message); nick != "" {
+		text = strings.TrimPrefix(text, "<"+nick+"> ")
+	}
+
+	text = strings.Join(append([]string{text}, message.Body...), "\n")
+
+	if searcher.Width > 0 {
+		text = wrapText(text, searcher.Width)
+	}
+
+	searcher.printRecord(text)
+
+	searcher.previous = scoredMessage{file: file, message: message}
+}
+
+// strict makes readMessages to fail on incomplete message at the end of
+// history file instead of skipping it.
+var strict bool
+
+// readMessages parses history records from given reader and calls handler
+// for every record.
+func readMessages(
+	name string,
+	reader io.Reader,
+	handler func(*Message) error,
+) error {
+	return readHeaderFilteredMessages(name, reader, nil, handler)
+}
+
+// readHeaderFilteredMessages is same as readMessages, but skips records,
+// which headers are not accepted by given function, without collecting their
+// body lines.
+func readHeaderFilteredMessages(
+	name string,
+	reader io.Reader,
+	accept func(*Header) bool,
+	handler func(*Message) error,
+) error {
+	var (
+		offset     int64
+		terminated bool
+		resyncing  bool
+		previous   time.Time
+	)
+
+	// Default bufio.ScanLines split function drops trailing \r, so files
+	// with CRLF line endings are parsed same way as files with LF ones.
+	// Split function is wrapped to count bytes, consumed by every line, and
+	// to find out whether line is terminated by newline.
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(
+		func(data []byte, atEOF bool) (int, []byte, error) {
+			advance, token, err := bufio.ScanLines(data, atEOF)
+			offset += int64(advance)
+			terminated = advance > len(token)
+
+			return advance, token, err
+		},
+	)
+
+	for {
+		start := offset
+
+		if !scanner.Scan() {
+			break
+		}
+
+		header, err := parseHeader(scanner.Text())
+		if err == nil && resyncing && robustResync {
+			err = checkPlausibleHeader(header, previous)
+		}
+
+		if err != nil {
+			// Last line without newline is most likely being written by
+			// mcabber right now, so it is not an error.
+			if !terminated && !strict {
+				break
+			}
+
+			if skipErrors {
+				if !resyncing {
+					slog.Warn(
+						"skipping malformed lines",
+						"file", name,
+						"offset", start,
+						"error", err,
+					)
+				}
+
+				resyncing = true
+
+				continue
+			}
+
+			// Rest of file can't be parsed, so it is skipped, if errors
+			// are collected.
+			return fileErrors.Collect(ser.Errorf(
+				err,
+				"line malformed: %q (file %q)",
+				scanner.Text(),
+				name,
+			))
+		}
+
+		resyncing = false
+
+		// Body lines are consumed below as is, so they are never parsed as
+		// headers, even if they look like ones.
+		message := &Message{
+			Header:       header,
+			Offset:       start,
+			PreviousTime: previous,
+		}
+
+		previous = header.Time
+
+		accepted := accept == nil || accept(header)
+
+		for i := 0; i < header.Length; i++ {
+			if !scanner.Scan() {
+				if scanner.Err() != nil {
+					return scanner.Err()
+				}
+
+				if !strict {
+					return nil
+				}
+
+				return fileErrors.Collect(fmt.Errorf(
+					"not enough lines in message (%d) (file %q)",
+					header.Length,
+					name,
+				))
+			}
+
+			if accepted {
+				message.Body = append(message.Body, scanner.Text())
+			}
+		}
+
+		if !accepted {
+			continue
+		}
+
+		message.Size = offset - start
+
+		err = handler(message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Text returns message text without any formatting: header message line,
+// followed by body lines.
+func (message *Message) Text() string {
+	return strings.Join(
+		append([]string{message.Header.Message}, message.Body...),
+		"\n",
+	)
+}
+
+// Content returns message text without sender nick and surrounding
+// whitespace.
+func (message *Message) Content() string {
+	text := message.Text()
+
+	if nick := extractNick(message); nick != "" {
+		text = strings.TrimPrefix(text, "<"+nick+">")
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// formatPlainMessage renders message for printing in minimal form: time,
+// sender nick, if any, and message text.
+func formatPlainMessage(message *Message) string {
+	text := message.Header.Message
+
+	if nick := extractNick(message); nick != "" {
+		text = nick + ":" + strings.TrimPrefix(text, "<"+nick+">")
+	}
+
+	lines := append(
+		[]string{message.Header.Time.Format("15:04") + " " + text},
+		message.Body...,
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+// formatMessage renders message for printing: colored direction and time,
+// followed by message text.
+func formatMessage(message *Message) string {
+	return formatMessageWithTime(message, time.ANSIC)
+}
+
+// formatMessageWithTime is same as formatMessage, but formats time using
+// specified layout.
+func formatMessageWithTime(message *Message, layout string) string {
+	var (
+		direction string
+		text      = message.Header.Message
+	)
+
+	switch message.Header.Direction {
+	case DirectionRecv:
+		direction = color.GreenString(">>>")
+
+	case DirectionSend:
+		direction = color.RedString("<<<")
+
+	case DirectionInfo:
+		direction = color.YellowString("---")
+		text = colorizePresence(message)
+	}
+
+	lines := append(
+		[]string{
+			fmt.Sprintf("%s %s %s",
+				direction,
+				color.BlueString(message.Header.Time.Format(layout)),
+				text,
+			),
+		},
+		message.Body...,
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+// foldQuotes replaces every run of consecutive lines, starting with specified
+// prefix, with single placeholder line.
+func foldQuotes(lines []string, prefix string) []string {
+	var (
+		folded = []string{}
+		quoted = 0
+	)
+
+	flush := func() {
+		if quoted > 0 {
+			noun := "lines"
+			if quoted == 1 {
+				noun = "line"
+			}
+
+			folded = append(
+				folded,
+				color.CyanString("[%d quoted %s]", quoted, noun),
+			)
+		}
+
+		quoted = 0
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			quoted++
+			continue
+		}
+
+		flush()
+
+		folded = append(folded, line)
+	}
+
+	flush()
+
+	return folded
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// readTestMessages parses history file with specified content and returns
+// its messages.
+func readTestMessages(
+	t *testing.T,
+	content string,
+	argv ...string,
+) ([]*Message, error) {
+	t.Helper()
+
+	args := parseTestArgs(t,
+		append([]string{"-S"}, append(argv, "work")...)...,
+	)
+
+	searcher, err := newSearcher(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []*Message{}
+
+	err = searcher.read(
+		HistoryFile{Name: "work"},
+		strings.NewReader(content),
+	)(func(message *Message) error {
+		messages = append(messages, message)
+
+		return nil
+	})
+
+	if err == nil {
+		err = fileErrors.Err()
+	}
+
+	return messages, err
+}
+
+func TestReadCRLF(t *testing.T) {
+	lines := []string{
+		"MR 20240102T10:00:00Z 001 <alice> deploy failed",
+		"see logs",
+		"MS 20240102T10:01:30Z 000 <me> looking",
+		"MI 20240102T10:02:00Z 000 bob has joined",
+	}
+
+	lf, err := readTestMessages(t,
+		strings.Join(lines, "\n")+"\n", "--include-info",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlf, err := readTestMessages(t,
+		strings.Join(lines, "\r\n")+"\r\n", "--include-info",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(crlf) != 3 || len(lf) != 3 {
+		t.Fatalf(
+			"got %d messages with CRLF, %d with LF, want 3",
+			len(crlf), len(lf),
+		)
+	}
+
+	want := []time.Time{
+		time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 10, 1, 30, 0, time.UTC),
+		time.Date(2024, 1, 2, 10, 2, 0, 0, time.UTC),
+	}
+
+	for index, message := range crlf {
+		if !message.Header.Time.Equal(want[index]) {
+			t.Errorf(
+				"message %d time is %s, want %s",
+				index, message.Header.Time, want[index],
+			)
+		}
+
+		if message.Header.Message != lf[index].Header.Message ||
+			!reflect.DeepEqual(message.Body, lf[index].Body) {
+			t.Errorf(
+				"message %d is %q %q with CRLF, want %q %q",
+				index,
+				message.Header.Message, message.Body,
+				lf[index].Header.Message, lf[index].Body,
+			)
+		}
+	}
+
+	if !reflect.DeepEqual(crlf[0].Body, []string{"see logs"}) {
+		t.Errorf("body is %q, want %q", crlf[0].Body, []string{"see logs"})
+	}
+}
+
+func TestExactMatchWhitespace(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> +1",
+		"MR 20240102T10:01:00Z 000 <bob>   +1  ",
+		"MR 20240102T10:02:00Z 000 <carol> +1\t",
+		"MR 20240102T10:03:00Z 000 <dave> +1 thanks",
+		"MR 20240102T10:04:00Z 000 <erin> + 1",
+		"MR 20240102T10:05:00Z 000 <frank> ++1",
+		"MR 20240102T10:06:00Z 001 <grace> +1",
+		"+1",
+	)
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--plain",
+		"--exact-match", "--fixed-strings", "work", "+1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		_, sender, _ := strings.Cut(line, " ")
+		sender, _, _ = strings.Cut(sender, ":")
+
+		got = append(got, sender)
+	}
+
+	want := []string{"alice", "bob", "carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got matches of %q, want %q; output:\n%s", got, want, output)
+	}
+}
+
+func TestReadTruncatedBody(t *testing.T) {
+	content := "MR 20240102T10:00:00Z 000 <alice> hello\n" +
+		"MR 20240102T10:01:00Z 002 <bob> deploy failed\n" +
+		"first line\n"
+
+	messages, err := readTestMessages(t, content)
+	if err != nil {
+		t.Fatalf("incomplete message should be skipped, got: %s", err)
+	}
+
+	if len(messages) != 1 {
+		t.Errorf("got %d messages, want 1", len(messages))
+	}
+
+	_, err = readTestMessages(t, content, "--strict")
+	if err == nil || !strings.Contains(err.Error(), "not enough lines") {
+		t.Errorf("got error %v, want error about not enough lines", err)
+	}
+}
+
+func TestReadIncompleteHeader(t *testing.T) {
+	content := "MR 20240102T10:00:00Z 000 <alice> hello\n" +
+		"MR 20240102T10:01:00Z 001 <bob> deploy\n" +
+		"failed\n" +
+		"MR 20240102T1"
+
+	messages, err := readTestMessages(t, content)
+	if err != nil {
+		t.Fatalf("incomplete last line should be skipped, got: %s", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+
+	if !reflect.DeepEqual(messages[1].Body, []string{"failed"}) {
+		t.Errorf("body is %q, want %q", messages[1].Body, []string{"failed"})
+	}
+
+	_, err = readTestMessages(t, content, "--strict")
+	if err == nil {
+		t.Errorf("incomplete last line should fail with --strict")
+	}
+
+	_, err = readTestMessages(t, content+"\n")
+	if err == nil {
+		t.Errorf("malformed terminated line should fail")
+	}
+}
+
+func TestAfterSilenceBoundary(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> first",
+		"MR 20240102T10:09:59Z 000 <alice> short",
+		"MR 20240102T10:19:59Z 000 <alice> exact",
+		"MR 20240102T10:29:58Z 000 <alice> shorter",
+		"MR 20240102T10:40:00Z 000 <alice> longer",
+	)
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--plain",
+		"--after-silence", "10m", "work",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		_, text, ok := strings.Cut(line, "alice: ")
+		if ok {
+			got = append(got, text)
+		}
+	}
+
+	want := []string{"first", "exact", "longer"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFoldQuotes(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	got := foldQuotes(
+		[]string{"> one", "reply", "> two", "> three", "end"},
+		">",
+	)
+
+	want := []string{
+		"[1 quoted line]", "reply", "[2 quoted lines]", "end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLineRegexpJSONText(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 002 <alice> header",
+		"deploy failed",
+		"all good",
+	)
+
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	output, err := runSearch(t,
+		"--path", dir, "--since", "100000h", "--json",
+		"--line-regexp", "work", "deploy",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message JSONMessage
+
+	err = json.Unmarshal([]byte(strings.SplitN(output, "\n", 2)[0]), &message)
+	if err != nil {
+		t.Fatalf("can't decode %q: %s", output, err)
+	}
+
+	want := "<alice> header\ndeploy failed\nall good"
+	if message.Text != want {
+		t.Errorf("got text %q, want %q", message.Text, want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// SeriesReport counts matching messages in fixed-size time buckets over
+// search window, aligned to bucket size, including empty buckets, so it can
+// be fed to time-series databases as is.
+type SeriesReport struct {
+	Interval time.Duration
+	Since    time.Time
+	Until    time.Time
+
+	counts map[time.Time]int
+}
+
+// Bucket is a number of matching messages in time interval, starting at
+// specified time.
+type Bucket struct {
+	Start time.Time `json:"bucket_start"`
+	Count int       `json:"count"`
+}
+
+func (report *SeriesReport) Add(file HistoryFile, message *Message) error {
+	if report.counts == nil {
+		report.counts = map[time.Time]int{}
+	}
+
+	report.counts[message.Header.Time.UTC().Truncate(report.Interval)]++
+
+	return nil
+}
+
+func (report *SeriesReport) Print(asJSON bool) error {
+	buckets := []Bucket{}
+
+	for start := report.Since.UTC().Truncate(report.Interval); !start.After(
+		report.Until,
+	); start = start.Add(report.Interval) {
+		buckets = append(buckets, Bucket{
+			Start: start,
+			Count: report.counts[start],
+		})
+	}
+
+	if asJSON {
+		return printJSON(buckets)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "bucket start\tcount")
+
+	for _, bucket := range buckets {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\n",
+			bucket.Start.Format(time.RFC3339),
+			bucket.Count,
+		)
+	}
+
+	return writer.Flush()
+}
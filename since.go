@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+const defaultSince = 24 * time.Hour
+
+// asOfLayouts are accepted formats of --as-of time.
+var asOfLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// relativeUnit matches single component of relative time, like 2w.
+var relativeUnit = regexp.MustCompile(`(\d+)(mo|ms|us|µs|ns|[ywdhms])`)
+
+// atTimeTolerance is a maximum difference between --at-time and time of
+// message, which is printed for it.
+const atTimeTolerance = time.Second
+
+// parseAtTime parses --at-time value, either in format of history file
+// headers, like 20060102T15:04:05Z, or in one of --as-of formats.
+func parseAtTime(value string) (time.Time, error) {
+	moment, err := time.Parse("20060102T15:04:05Z", value)
+	if err == nil {
+		return moment, nil
+	}
+
+	for _, layout := range asOfLayouts {
+		moment, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return moment, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"can't parse time %q: should be in form of %q or %q",
+		value, "20060102T15:04:05Z", "2006-01-02 15:04:05",
+	)
+}
+
+// parseAsOf returns moment, which relative times are counted from: --as-of
+// time or current time, if it's not specified.
+func parseAsOf(args map[string]interface{}) (time.Time, error) {
+	value, ok := args["--as-of"].(string)
+	if !ok {
+		return time.Now(), nil
+	}
+
+	for _, layout := range asOfLayouts {
+		moment, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return moment, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"can't parse time %q: should be in form of %q",
+		value, "2006-01-02 15:04:05",
+	)
+}
+
+// parseRelative returns moment, which is specified duration before now.
+// Besides units of time.ParseDuration, duration can be specified in days
+// (d), weeks (w), months (mo) and years (y), like 2w or 1y6mo. Days and
+// weeks are counted by calendar, so they are not affected by DST changes,
+// and months and years are counted like in time.AddDate.
+func parseRelative(value string, now time.Time) (time.Time, error) {
+	duration, err := time.ParseDuration(value)
+	if err == nil {
+		return now.Add(-duration), nil
+	}
+
+	components := relativeUnit.FindAllStringSubmatch(value, -1)
+
+	matched := ""
+	for _, component := range components {
+		matched += component[0]
+	}
+
+	if len(components) == 0 || matched != value {
+		return time.Time{}, fmt.Errorf(
+			"can't parse time duration %q: should be like %s",
+			value, "90m, 24h, 3d, 2w, 1mo or 1y",
+		)
+	}
+
+	var years, months, days int
+
+	for _, component := range components {
+		amount, err := strconv.Atoi(component[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf(
+				"can't parse time duration %q: %s",
+				value, err,
+			)
+		}
+
+		switch unit := component[2]; unit {
+		case "y":
+			years += amount
+
+		case "mo":
+			months += amount
+
+		case "w":
+			days += amount * 7
+
+		case "d":
+			days += amount
+
+		default:
+			duration, err := time.ParseDuration(component[1] + unit)
+			if err != nil {
+				return time.Time{}, fmt.Errorf(
+					"can't parse time duration %q: %s",
+					value, err,
+				)
+			}
+
+			now = now.Add(-duration)
+		}
+	}
+
+	return now.AddDate(-years, -months, -days), nil
+}
+
+// parseSince returns time of the oldest message to match, specified either
+// by modification time of --since-file or by --since duration before
+// specified moment.
+func parseSince(
+	args map[string]interface{},
+	now time.Time,
+) (time.Time, error) {
+	value, sinceGiven := args["--since"].(string)
+
+	if path, ok := args["--since-file"].(string); ok {
+		stat, err := os.Stat(path)
+		switch {
+		case err == nil:
+			return stat.ModTime(), nil
+
+		case !os.IsNotExist(err):
+			return time.Time{}, ser.Errorf(
+				err,
+				"can't stat since file %q",
+				path,
+			)
+
+		case !sinceGiven:
+			return time.Time{}, fmt.Errorf(
+				"since file %q doesn't exist and --since is not specified",
+				path,
+			)
+		}
+	}
+
+	if !sinceGiven {
+		return now.Add(-defaultSince), nil
+	}
+
+	return parseRelative(value, now)
+}
+
+// parseBaselineSince returns start of baseline window for --diff, which
+// should be older than specified start of new window. Duration is counted
+// back from specified moment.
+func parseBaselineSince(
+	args map[string]interface{},
+	since time.Time,
+	now time.Time,
+) (time.Time, error) {
+	value, ok := args["--baseline-since"].(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("--diff requires --baseline-since")
+	}
+
+	baseline, err := parseRelative(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !baseline.Before(since) {
+		return time.Time{}, fmt.Errorf(
+			"--baseline-since %q should be longer than --since",
+			value,
+		)
+	}
+
+	return baseline, nil
+}
+
+// touchSinceFile sets modification time of --since-file to specified time,
+// so next search with that file will start from it.
+func touchSinceFile(args map[string]interface{}, moment time.Time) error {
+	path, ok := args["--since-file"].(string)
+	if !ok {
+		return fmt.Errorf("--touch-since-file requires --since-file")
+	}
+
+	handle, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't create since file %q",
+			path,
+		)
+	}
+
+	handle.Close()
+
+	err = os.Chtimes(path, moment, moment)
+	if err != nil {
+		return ser.Errorf(
+			err,
+			"can't update modification time of since file %q",
+			path,
+		)
+	}
+
+	return nil
+}
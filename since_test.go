@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRelative(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"90m", time.Date(2024, 3, 31, 10, 30, 0, 0, time.UTC)},
+		{"24h", time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)},
+		{"3d", time.Date(2024, 3, 28, 12, 0, 0, 0, time.UTC)},
+		{"2w", time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"1mo", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{"1y", time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{"1y6mo", time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{"1d12h", time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := parseRelative(test.value, now)
+		if err != nil {
+			t.Errorf("parseRelative(%q): %s", test.value, err)
+			continue
+		}
+
+		if !got.Equal(test.want) {
+			t.Errorf(
+				"parseRelative(%q) = %s, want %s",
+				test.value, got, test.want,
+			)
+		}
+	}
+}
+
+func TestParseRelativeInvalid(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"", "2", "5x", "3dd", "d", "1mo-"} {
+		_, err := parseRelative(value, now)
+		if err == nil {
+			t.Errorf("parseRelative(%q) should fail", value)
+		}
+	}
+}
+
+func TestAsOfReproducible(t *testing.T) {
+	dir := t.TempDir()
+
+	// --as-of is specified in local time, while history is written in UTC.
+	asOf := time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local)
+
+	lines := []string{}
+	for _, offset := range []time.Duration{
+		-3 * time.Hour,
+		-90 * time.Minute,
+		-30 * time.Minute,
+		30 * time.Minute,
+	} {
+		lines = append(lines, fmt.Sprintf(
+			"MR %s 000 <alice> %s",
+			asOf.Add(offset).UTC().Format("20060102T15:04:05Z"),
+			offset,
+		))
+	}
+
+	writeHistory(t, dir, "work", lines...)
+
+	search := func() string {
+		output, err := runSearch(t,
+			"--path", dir, "--plain", "--since", "2h",
+			"--as-of", "2024-01-02 12:00", "work",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return output
+	}
+
+	want := search()
+
+	if !strings.Contains(want, "-1h30m0s") ||
+		!strings.Contains(want, "-30m0s") ||
+		strings.Contains(want, "-3h0m0s") ||
+		strings.Contains(want, " 30m0s") {
+		t.Fatalf("messages within 2h before --as-of expected, got:\n%s", want)
+	}
+
+	got := search()
+	if got != want {
+		t.Errorf("output differs between runs:\n%s\nand:\n%s", want, got)
+	}
+}
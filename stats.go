@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Stats is a statistics of search, which is printed by --stats.
+type Stats struct {
+	// Files is a number of history files searched.
+	Files int
+
+	// SkippedLarge is a number of history files skipped, because they are
+	// larger than --max-file-size.
+	SkippedLarge int
+
+	// SkippedIndex is a number of history files skipped, because index
+	// shows they don't contain filter terms.
+	SkippedIndex int
+
+	// Matched is a number of matching messages.
+	Matched int
+}
+
+// stats is collected during whole search.
+var stats Stats
+
+// Print prints statistics to stderr, so it doesn't mix with messages.
+func (stats Stats) Print() {
+	fmt.Fprintf(
+		os.Stderr,
+		"files searched: %d\nfiles skipped as too large: %d\n"+
+			"files skipped by index: %d\nmessages matched: %d\n",
+		stats.Files,
+		stats.SkippedLarge,
+		stats.SkippedIndex,
+		stats.Matched,
+	)
+}
+
+// parseSize parses size in bytes with optional K, M or G suffix, which are
+// powers of 1024.
+func parseSize(value string) (int64, error) {
+	var (
+		number     = strings.ToUpper(value)
+		multiplier = int64(1)
+	)
+
+	for suffix, power := range map[string]int64{
+		"K": 1 << 10,
+		"M": 1 << 20,
+		"G": 1 << 30,
+	} {
+		if strings.HasSuffix(number, suffix) {
+			number = strings.TrimSuffix(number, suffix)
+			multiplier = power
+		}
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("can't parse size %q", value)
+	}
+
+	return size * multiplier, nil
+}
+
+// parseMaxFileSize returns --max-file-size in bytes or zero, if it's not
+// specified.
+func parseMaxFileSize(args map[string]interface{}) (int64, error) {
+	value, ok := args["--max-file-size"].(string)
+	if !ok {
+		return 0, nil
+	}
+
+	return parseSize(value)
+}
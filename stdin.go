@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reconquest/ser-go"
+)
+
+// stdinChannel is a channel name, which means history, piped to stdin.
+const stdinChannel = "-"
+
+// Parser parses history records in some format from reader and calls
+// handler for every record, which header is accepted by given function.
+type Parser func(
+	name string,
+	reader io.Reader,
+	accept func(*Header) bool,
+	handler func(*Message) error,
+) error
+
+// parsers are formats of history, which can be piped to stdin.
+var parsers = map[string]Parser{
+	"mcabber": readHeaderFilteredMessages,
+	"generic": readGenericMessages,
+}
+
+// genericLayouts are accepted formats of time in generic format, which take
+// one or two fields of line.
+var genericLayouts = []struct {
+	layout string
+	fields int
+}{
+	{time.RFC3339, 1},
+	{"2006-01-02T15:04:05", 1},
+	{"2006-01-02 15:04:05", 2},
+	{"2006-01-02 15:04", 2},
+}
+
+// isStdin reports whether history should be read from stdin.
+func isStdin(args map[string]interface{}) bool {
+	channel, _ := args["<channel>"].(string)
+
+	return channel == stdinChannel
+}
+
+// readStdin calls handler for history, piped to stdin, which is parsed
+// according to --stdin-format.
+func readStdin(
+	args map[string]interface{},
+	handler func(file HistoryFile, reader io.Reader) error,
+) error {
+	format := args["--stdin-format"].(string)
+	if _, ok := parsers[format]; !ok {
+		return fmt.Errorf("unknown stdin format %q", format)
+	}
+
+	return handler(
+		HistoryFile{
+			Name:   "<stdin>",
+			Alias:  "stdin",
+			Format: format,
+		},
+		os.Stdin,
+	)
+}
+
+// readGenericMessages parses history in generic format of other chat tools:
+// one message per line in form of "<time> <nick>: <text>". Lines without
+// nick are parsed as info messages; all messages are considered received.
+func readGenericMessages(
+	name string,
+	reader io.Reader,
+	accept func(*Header) bool,
+	handler func(*Message) error,
+) error {
+	var (
+		offset   int64
+		number   int
+		previous time.Time
+	)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var (
+			line = scanner.Text()
+			size = int64(len(scanner.Bytes()) + 1)
+		)
+
+		number++
+
+		if strings.TrimSpace(line) == "" {
+			offset += size
+			continue
+		}
+
+		header, err := parseGenericLine(line)
+		if err != nil {
+			return fileErrors.Collect(ser.Errorf(
+				err,
+				"line %d malformed: %q (file %q)",
+				number,
+				line,
+				name,
+			))
+		}
+
+		message := &Message{
+			Header:       header,
+			Offset:       offset,
+			Size:         size,
+			PreviousTime: previous,
+		}
+
+		offset += size
+		previous = header.Time
+
+		if accept != nil && !accept(header) {
+			continue
+		}
+
+		err = handler(message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseGenericLine parses line of generic format into header with message
+// in form of mcabber multi-user chat messages, so nick is extracted as
+// usual.
+func parseGenericLine(line string) (*Header, error) {
+	fields := strings.Fields(line)
+
+	for _, layout := range genericLayouts {
+		if len(fields) <= layout.fields {
+			continue
+		}
+
+		moment, err := time.ParseInLocation(
+			layout.layout,
+			strings.Join(fields[:layout.fields], " "),
+			time.Local,
+		)
+		if err != nil {
+			continue
+		}
+
+		text := strings.TrimSpace(
+			strings.SplitAfterN(line, fields[layout.fields-1], 2)[1],
+		)
+
+		nick, rest, ok := strings.Cut(text, ": ")
+		if !ok || nick == "" || strings.ContainsAny(nick, " \t") {
+			return &Header{
+				Direction: DirectionInfo,
+				Time:      moment,
+				Message:   text,
+			}, nil
+		}
+
+		return &Header{
+			Direction: DirectionRecv,
+			Time:      moment,
+			Message:   "<" + nick + "> " + rest,
+		}, nil
+	}
+
+	return nil, fmt.Errorf(
+		"expected line in form of %q",
+		"2006-01-02 15:04:05 nick: text",
+	)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errNoMatches is returned by search, if --count-total found no matching
+// messages, so program exits with non-zero code, like grep does.
+var errNoMatches = errors.New("no matching messages")
+
+// TotalReport counts matching messages across all history files.
+type TotalReport struct {
+	Count int
+}
+
+func (report *TotalReport) Add(file HistoryFile, message *Message) error {
+	report.Count++
+
+	return nil
+}
+
+// Print prints total as bare integer, JSON or not, so it can be used in
+// scripts as is.
+func (report *TotalReport) Print(asJSON bool) error {
+	_, err := fmt.Fprintln(stdout, report.Count)
+
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// UnansweredDetector finds sent questions, which are not followed by any
+// received message within Window.
+type UnansweredDetector struct {
+	// Question matches content of sent messages, which are questions.
+	Question *regexp.Regexp
+
+	// Window is a time after question, which reply is expected within.
+	Window time.Duration
+}
+
+// Filter unmarks matching messages, which are not unanswered questions.
+// Messages should be all messages of single history file in order they are
+// written, so replies can be found.
+func (detector *UnansweredDetector) Filter(
+	messages []*Message,
+	matches []bool,
+) {
+	for index, matched := range matches {
+		if !matched {
+			continue
+		}
+
+		message := messages[index]
+
+		if message.Header.Direction != DirectionSend ||
+			!detector.Question.MatchString(message.Content()) {
+			matches[index] = false
+
+			continue
+		}
+
+		deadline := message.Header.Time.Add(detector.Window)
+
+		for _, next := range messages[index+1:] {
+			if next.Header.Time.After(deadline) {
+				break
+			}
+
+			if next.Header.Direction == DirectionRecv {
+				matches[index] = false
+
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// urlPattern matches URLs with scheme or starting with www. Trailing
+// punctuation is trimmed separately, see extractURLs.
+var urlPattern = regexp.MustCompile(
+	`(?i)\b(?:(?:https?|ftp|aesgcm)://|www\.)[^\s<>"'` + "`" + `]+`,
+)
+
+// extractURLs returns all URLs found in text. Trailing punctuation, which
+// usually belongs to sentence, is not included in URL, as well as closing
+// parenthesis without opening one, like in "(see http://example.com)".
+func extractURLs(text string) []string {
+	urls := []string{}
+	for _, url := range urlPattern.FindAllString(text, -1) {
+		for {
+			trimmed := strings.TrimRight(url, ".,;:!?'\"")
+
+			if strings.HasSuffix(trimmed, ")") &&
+				strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+				trimmed = strings.TrimSuffix(trimmed, ")")
+			}
+
+			if trimmed == url {
+				break
+			}
+
+			url = trimmed
+		}
+
+		urls = append(urls, url)
+	}
+
+	return urls
+}
+
+// URLsReport lists URLs found in matching messages.
+type URLsReport struct {
+	urls  []*SharedURL
+	index map[string]*SharedURL
+}
+
+// SharedURL is URL, which was found in messages, with time and channel of
+// the first message containing it.
+type SharedURL struct {
+	URL     string    `json:"url"`
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel"`
+	Count   int       `json:"count"`
+}
+
+func (report *URLsReport) Add(file HistoryFile, message *Message) error {
+	if report.index == nil {
+		report.index = map[string]*SharedURL{}
+	}
+
+	for _, url := range extractURLs(message.Text()) {
+		shared, ok := report.index[url]
+		if !ok {
+			shared = &SharedURL{
+				URL:     url,
+				Time:    message.Header.Time,
+				Channel: file.Channel(),
+			}
+
+			report.index[url] = shared
+			report.urls = append(report.urls, shared)
+		}
+
+		if message.Header.Time.Before(shared.Time) {
+			shared.Time = message.Header.Time
+			shared.Channel = file.Channel()
+		}
+
+		shared.Count++
+	}
+
+	return nil
+}
+
+func (report *URLsReport) Print(asJSON bool) error {
+	sort.SliceStable(report.urls, func(i, j int) bool {
+		return report.urls[i].Time.Before(report.urls[j].Time)
+	})
+
+	if asJSON {
+		urls := report.urls
+		if urls == nil {
+			urls = []*SharedURL{}
+		}
+
+		return printJSON(urls)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	for _, shared := range report.urls {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%d\t%s\n",
+			shared.Time.Format(time.ANSIC),
+			shared.Channel,
+			shared.Count,
+			shared.URL,
+		)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseWeekdays parses comma-separated list of weekdays, specified either by
+// name, full or abbreviated, like Monday or Mon, or by number from 0 to 7,
+// where both 0 and 7 mean Sunday.
+func parseWeekdays(value string) (map[time.Weekday]bool, error) {
+	weekdays := map[time.Weekday]bool{}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			return nil, fmt.Errorf("can't parse weekday %q", name)
+		}
+
+		weekdays[weekday] = true
+	}
+
+	return weekdays, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	if number, err := strconv.Atoi(name); err == nil {
+		if number < 0 || number > 7 {
+			return 0, false
+		}
+
+		return time.Weekday(number % 7), true
+	}
+
+	if len(name) < 3 {
+		return 0, false
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		full := weekday.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []time.Weekday
+	}{
+		{"Mon", []time.Weekday{time.Monday}},
+		{"monday, FRI", []time.Weekday{time.Monday, time.Friday}},
+		{"0", []time.Weekday{time.Sunday}},
+		{"7", []time.Weekday{time.Sunday}},
+		{"1,6", []time.Weekday{time.Monday, time.Saturday}},
+	}
+
+	for _, test := range tests {
+		got, err := parseWeekdays(test.value)
+		if err != nil {
+			t.Errorf("parseWeekdays(%q): %s", test.value, err)
+			continue
+		}
+
+		want := map[time.Weekday]bool{}
+		for _, weekday := range test.want {
+			want[weekday] = true
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseWeekdays(%q) = %v, want %v", test.value, got, want)
+		}
+	}
+
+	for _, value := range []string{"", "8", "-1", "Mo", "Mondays", "Mon,"} {
+		_, err := parseWeekdays(value)
+		if err == nil {
+			t.Errorf("parseWeekdays(%q) should fail", value)
+		}
+	}
+}
+
+func TestWeekdaySearch(t *testing.T) {
+	dir := t.TempDir()
+
+	// 2024-01-01 is Monday. Messages are written at noon, so their weekday
+	// is the same in any local time zone of tests.
+	lines := []string{}
+	for day := 1; day <= 7; day++ {
+		lines = append(lines, fmt.Sprintf(
+			"MR 202401%02dT12:00:00Z 000 <alice> %s",
+			day,
+			time.Date(2024, 1, day, 12, 0, 0, 0, time.UTC).Weekday(),
+		))
+	}
+
+	writeHistory(t, dir, "work", lines...)
+
+	tests := []struct {
+		weekday string
+		want    []string
+	}{
+		{"Mon", []string{"Monday"}},
+		{"sat,sun", []string{"Saturday", "Sunday"}},
+		{"1,3,5", []string{"Monday", "Wednesday", "Friday"}},
+		{"0", []string{"Sunday"}},
+	}
+
+	for _, test := range tests {
+		output, err := runSearch(t,
+			"--path", dir, "--since", "100000h", "--plain",
+			"--weekday", test.weekday, "work",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := []string{}
+		for _, line := range strings.Split(output, "\n") {
+			_, text, ok := strings.Cut(line, "alice: ")
+			if ok {
+				got = append(got, text)
+			}
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"--weekday %s printed %q, want %q",
+				test.weekday, got, test.want,
+			)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+)
+
+// normalizeWhitespace collapses runs of whitespace in line into single
+// spaces and trims it.
+func normalizeWhitespace(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// normalizeMessageWhitespace returns copy of message with normalized
+// whitespace in header message and every body line.
+func normalizeMessageWhitespace(message *Message) *Message {
+	header := *message.Header
+	header.Message = normalizeWhitespace(header.Message)
+
+	normalized := *message
+	normalized.Header = &header
+	normalized.Body = nil
+
+	for _, line := range message.Body {
+		normalized.Body = append(normalized.Body, normalizeWhitespace(line))
+	}
+
+	return &normalized
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"deploy failed", "deploy failed"},
+		{"deploy\tfailed", "deploy failed"},
+		{"deploy  \t  failed", "deploy failed"},
+		{"\t deploy failed  ", "deploy failed"},
+		{" \t ", ""},
+	}
+
+	for _, test := range tests {
+		got := normalizeWhitespace(test.line)
+		if got != test.want {
+			t.Errorf("normalizeWhitespace(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeWhitespaceSearch(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 001 <alice> deploy \t failed",
+		"\tdeploy    failed   again",
+		"MR 20240102T10:01:00Z 000 <bob> deploy succeeded",
+	)
+
+	tests := []struct {
+		name    string
+		options []string
+		want    int
+	}{
+		{"plain", nil, 0},
+		{"normalized", []string{"--normalize-whitespace"}, 1},
+		{
+			"normalized with prefilter",
+			[]string{"--normalize-whitespace", "--bloom-prefilter"},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			argv := append([]string{
+				"--path", dir, "--since", "100000h", "--phrase", "--plain",
+			}, test.options...)
+
+			output, err := runSearch(t, append(argv, "work", "deploy", "failed")...)
+			if err != nil && err != errNoMatches {
+				t.Fatal(err)
+			}
+
+			got := strings.Count(output, "alice: ")
+			if got != test.want {
+				t.Errorf("got %d matches, want %d; output:\n%s", got, test.want, output)
+			}
+		})
+	}
+}
+
+func TestNormalizeDisplay(t *testing.T) {
+	dir := t.TempDir()
+
+	writeHistory(t, dir, "work",
+		"MR 20240102T10:00:00Z 000 <alice> deploy \t failed",
+	)
+
+	argv := []string{"--path", dir, "--since", "100000h", "--phrase", "--plain"}
+
+	output, err := runSearch(t, append(argv,
+		"--normalize-whitespace", "work", "deploy", "failed",
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "deploy \t failed") {
+		t.Errorf("original spacing should be printed, got:\n%s", output)
+	}
+
+	output, err = runSearch(t, append(argv,
+		"--normalize-whitespace", "--normalize-display", "work", "deploy", "failed",
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "deploy failed") {
+		t.Errorf("normalized spacing should be printed, got:\n%s", output)
+	}
+}
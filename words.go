@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reconquest/ser-go"
+)
+
+// defaultStopwords are common english words, which are skipped by word
+// frequency report, unless --stopwords file is specified.
+var defaultStopwords = []string{
+	"a", "about", "all", "also", "am", "an", "and", "any", "are", "as", "at",
+	"be", "been", "but", "by", "can", "could", "did", "do", "does", "for",
+	"from", "had", "has", "have", "he", "her", "him", "his", "how", "i",
+	"if", "in", "into", "is", "it", "its", "just", "me", "my", "no", "not",
+	"of", "on", "or", "our", "she", "so", "than", "that", "the", "their",
+	"them", "then", "there", "they", "this", "to", "too", "up", "us", "was",
+	"we", "were", "what", "when", "which", "who", "will", "with", "would",
+	"you", "your",
+}
+
+// WordsReport counts words in matching messages and prints most frequent
+// ones.
+type WordsReport struct {
+	Count     int
+	Stopwords map[string]bool
+
+	counts map[string]int
+}
+
+// WordCount is a word and number of its occurrences.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// newWordsReport creates report of specified number of most frequent words,
+// skipping stopwords from specified file, one per line, or default ones, if
+// path is empty.
+func newWordsReport(count int, path string) (*WordsReport, error) {
+	report := &WordsReport{
+		Count:     count,
+		Stopwords: map[string]bool{},
+		counts:    map[string]int{},
+	}
+
+	if path == "" {
+		for _, word := range defaultStopwords {
+			report.Stopwords[word] = true
+		}
+
+		return report, nil
+	}
+
+	handle, err := os.Open(path)
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't open stopwords file %q",
+			path,
+		)
+	}
+
+	defer handle.Close()
+
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+
+		report.Stopwords[strings.ToLower(word)] = true
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, ser.Errorf(
+			err,
+			"can't read stopwords file %q",
+			path,
+		)
+	}
+
+	return report, nil
+}
+
+func (report *WordsReport) Add(file HistoryFile, message *Message) error {
+	for _, word := range splitWords(message.Content()) {
+		word = strings.ToLower(strings.Trim(word, "-_"))
+		if word == "" || report.Stopwords[word] {
+			continue
+		}
+
+		report.counts[word]++
+	}
+
+	return nil
+}
+
+func (report *WordsReport) Print(asJSON bool) error {
+	words := []WordCount{}
+	for word, count := range report.counts {
+		words = append(words, WordCount{Word: word, Count: count})
+	}
+
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+
+		return words[i].Word < words[j].Word
+	})
+
+	if len(words) > report.Count {
+		words = words[:report.Count]
+	}
+
+	if asJSON {
+		return printJSON(words)
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	for _, word := range words {
+		fmt.Fprintf(writer, "%s\t%d\n", word.Word, word.Count)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// wrapIndent is prepended to continuation lines of wrapped line.
+const wrapIndent = "    "
+
+// escapeSequence matches ANSI CSI sequence, like color one, at the start of
+// text; such sequences don't take place on screen.
+var escapeSequence = regexp.MustCompile(`^\x1b\[[0-?]*[ -/]*[@-~]`)
+
+// parseWrapWidth returns width to wrap output at: --width, if specified, or
+// width of terminal, if --wrap is specified and output is terminal. Zero
+// means that output should not be wrapped.
+func parseWrapWidth(args map[string]interface{}) (int, error) {
+	if value, ok := args["--width"].(string); ok {
+		width, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("can't parse width %q: %s", value, err)
+		}
+
+		return width, nil
+	}
+
+	if !args["--wrap"].(bool) {
+		return 0, nil
+	}
+
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0, nil
+	}
+
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0, nil
+	}
+
+	return width, nil
+}
+
+// wrapText hard-wraps every line of text at specified width, preferring to
+// break lines at spaces.
+func wrapText(text string, width int) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, wrapLine(line, width)...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func wrapLine(line string, width int) []string {
+	if width <= len(wrapIndent)+1 {
+		return []string{line}
+	}
+
+	var (
+		lines   = []string{}
+		current = []byte{}
+		column  = 0
+
+		// space is position of last space in current line and spaceColumn
+		// is its column on screen.
+		space       = -1
+		spaceColumn = 0
+	)
+
+	for len(line) > 0 {
+		if sequence := escapeSequence.FindString(line); sequence != "" {
+			current = append(current, sequence...)
+			line = line[len(sequence):]
+
+			continue
+		}
+
+		char, size := utf8.DecodeRuneInString(line)
+
+		if column >= width {
+			if space >= 0 {
+				lines = append(lines, string(current[:space]))
+
+				current = append([]byte(wrapIndent), current[space+1:]...)
+				column = len(wrapIndent) + column - spaceColumn - 1
+			} else {
+				lines = append(lines, string(current))
+
+				current = []byte(wrapIndent)
+				column = len(wrapIndent)
+			}
+
+			space = -1
+		}
+
+		if char == ' ' {
+			space = len(current)
+			spaceColumn = column
+		}
+
+		current = append(current, line[:size]...)
+		line = line[size:]
+		column++
+	}
+
+	return append(lines, string(current))
+}